@@ -0,0 +1,533 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// objectIDParam parses the URL parameter name, answering 400 when it is
+// not an ObjectID.
+func objectIDParam(w http.ResponseWriter, r *http.Request, name string) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, name))
+	if err != nil {
+		http.Error(w, "Invalid "+name, http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// findOne decodes the document with _id id from c, answering 404 or 500
+// when it cannot.
+func findOne(ctx context.Context, w http.ResponseWriter, c *mongo.Collection, id primitive.ObjectID, what string, dst any) bool {
+	err := c.FindOne(ctx, bson.M{"_id": id}).Decode(dst)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, what+" not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func validateCourse(c *Course) map[string]string {
+	errs := map[string]string{}
+	c.Title = strings.TrimSpace(c.Title)
+	if c.Title == "" {
+		errs["title"] = "required"
+	} else if len(c.Title) > 200 {
+		errs["title"] = "must be at most 200 characters"
+	}
+	switch c.Status {
+	case "":
+		c.Status = taskDraft
+	case taskDraft, taskPublished, taskArchived:
+	default:
+		errs["status"] = "must be draft, published or archived"
+	}
+	if c.Order < 0 {
+		errs["order"] = "must not be negative"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateModule(m *Module) map[string]string {
+	m.Title = strings.TrimSpace(m.Title)
+	if m.Title == "" {
+		return map[string]string{"title": "required"}
+	}
+	if m.Order < 0 {
+		return map[string]string{"order": "must not be negative"}
+	}
+	return nil
+}
+
+// validateLesson checks a lesson against the rest of its course: tasks
+// must exist, prerequisites must be other lessons of the same course and
+// must not form a cycle.
+func (s *Server) validateLesson(ctx context.Context, l *Lesson) (map[string]string, error) {
+	errs := map[string]string{}
+
+	l.Title = strings.TrimSpace(l.Title)
+	if l.Title == "" {
+		errs["title"] = "required"
+	}
+	if l.Order < 0 {
+		errs["order"] = "must not be negative"
+	}
+	if l.TaskIDs == nil {
+		l.TaskIDs = []primitive.ObjectID{}
+	}
+	if l.HandbookPages == nil {
+		l.HandbookPages = []string{}
+	}
+	if l.Prerequisites == nil {
+		l.Prerequisites = []primitive.ObjectID{}
+	}
+
+	if len(l.TaskIDs) > 0 {
+		n, err := s.tasks.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": l.TaskIDs}})
+		if err != nil {
+			return nil, err
+		}
+		if int(n) != len(l.TaskIDs) {
+			errs["taskIds"] = "unknown or duplicate task"
+		}
+	}
+
+	cur, err := s.lessons.Find(ctx, bson.M{"courseId": l.CourseID},
+		options.Find().SetProjection(bson.M{"prerequisites": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var siblings []Lesson
+	if err := cur.All(ctx, &siblings); err != nil {
+		return nil, err
+	}
+
+	prereqs := map[primitive.ObjectID][]primitive.ObjectID{l.ID: l.Prerequisites}
+	for _, sib := range siblings {
+		if sib.ID != l.ID {
+			prereqs[sib.ID] = sib.Prerequisites
+		}
+	}
+	for _, p := range l.Prerequisites {
+		if _, ok := prereqs[p]; !ok || p == l.ID {
+			errs["prerequisites"] = "must be other lessons of the same course"
+			break
+		}
+	}
+	if errs["prerequisites"] == "" && reachable(prereqs, l.Prerequisites, l.ID) {
+		errs["prerequisites"] = "would create a cycle"
+	}
+
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	return errs, nil
+}
+
+// reachable reports whether target can be reached from start by following
+// prerequisite edges.
+func reachable(edges map[primitive.ObjectID][]primitive.ObjectID, start []primitive.ObjectID, target primitive.ObjectID) bool {
+	seen := map[primitive.ObjectID]bool{}
+	stack := append([]primitive.ObjectID(nil), start...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		stack = append(stack, edges[id]...)
+	}
+	return false
+}
+
+func (s *Server) handleAdminListCourses(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.courses.Find(ctx, bson.M{}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	courses := []Course{}
+	if err := cur.All(ctx, &courses); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, courses)
+}
+
+func (s *Server) handleAdminCreateCourse(w http.ResponseWriter, r *http.Request) {
+	var c Course
+	if err := decodeJSON(r, &c); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateCourse(&c); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	c.ID = primitive.NewObjectID()
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+	if _, err := s.courses.InsertOne(ctx, c); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// handleAdminGetCourse returns the full tree regardless of status.
+func (s *Server) handleAdminGetCourse(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var c Course
+	if !findOne(ctx, w, s.courses, id, "Course", &c) {
+		return
+	}
+	tree, _, err := s.loadCourseTree(ctx, c)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+func (s *Server) handleAdminUpdateCourse(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var c Course
+	if err := decodeJSON(r, &c); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateCourse(&c); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var old Course
+	if !findOne(ctx, w, s.courses, id, "Course", &old) {
+		return
+	}
+	c.ID = old.ID
+	c.CreatedAt = old.CreatedAt
+	c.UpdatedAt = time.Now().UTC()
+	if _, err := s.courses.ReplaceOne(ctx, bson.M{"_id": id}, c); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// handleAdminDeleteCourse removes a course with its modules, lessons and
+// the completions recorded for them.
+func (s *Server) handleAdminDeleteCourse(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	res, err := s.courses.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.deleteLessons(ctx, bson.M{"courseId": id}); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.modules.DeleteMany(ctx, bson.M{"courseId": id}); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteLessons removes the lessons matching filter, their completions
+// and any prerequisite references to them.
+func (s *Server) deleteLessons(ctx context.Context, filter bson.M) error {
+	cur, err := s.lessons.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var docs []Lesson
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+
+	if _, err := s.lessons.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	if _, err := s.completions.DeleteMany(ctx, bson.M{"lessonId": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	_, err = s.lessons.UpdateMany(ctx,
+		bson.M{"prerequisites": bson.M{"$in": ids}},
+		bson.M{"$pull": bson.M{"prerequisites": bson.M{"$in": ids}}},
+	)
+	return err
+}
+
+func (s *Server) handleAdminCreateModule(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var m Module
+	if err := decodeJSON(r, &m); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateModule(&m); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var c Course
+	if !findOne(ctx, w, s.courses, courseID, "Course", &c) {
+		return
+	}
+
+	m.ID = primitive.NewObjectID()
+	m.CourseID = courseID
+	m.CreatedAt = time.Now().UTC()
+	if _, err := s.modules.InsertOne(ctx, m); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleAdminUpdateModule(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var m Module
+	if err := decodeJSON(r, &m); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateModule(&m); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var old Module
+	if !findOne(ctx, w, s.modules, id, "Module", &old) {
+		return
+	}
+	m.ID = old.ID
+	m.CourseID = old.CourseID
+	m.CreatedAt = old.CreatedAt
+	if _, err := s.modules.ReplaceOne(ctx, bson.M{"_id": id}, m); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) handleAdminDeleteModule(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	res, err := s.modules.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, "Module not found", http.StatusNotFound)
+		return
+	}
+	if err := s.deleteLessons(ctx, bson.M{"moduleId": id}); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminCreateLesson(w http.ResponseWriter, r *http.Request) {
+	moduleID, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var l Lesson
+	if err := decodeJSON(r, &l); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var m Module
+	if !findOne(ctx, w, s.modules, moduleID, "Module", &m) {
+		return
+	}
+
+	l.ID = primitive.NewObjectID()
+	l.ModuleID = m.ID
+	l.CourseID = m.CourseID
+	errs, err := s.validateLesson(ctx, &l)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	l.CreatedAt = time.Now().UTC()
+	if _, err := s.lessons.InsertOne(ctx, l); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// handleAdminUpdateLesson replaces a lesson's content. Setting moduleId
+// moves it to another module of the same course.
+func (s *Server) handleAdminUpdateLesson(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var l Lesson
+	if err := decodeJSON(r, &l); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var old Lesson
+	if !findOne(ctx, w, s.lessons, id, "Lesson", &old) {
+		return
+	}
+
+	if l.ModuleID.IsZero() {
+		l.ModuleID = old.ModuleID
+	} else if l.ModuleID != old.ModuleID {
+		var m Module
+		err := s.modules.FindOne(ctx, bson.M{"_id": l.ModuleID, "courseId": old.CourseID}).Decode(&m)
+		if err == mongo.ErrNoDocuments {
+			writeValidationErrors(w, map[string]string{"moduleId": "must be a module of the same course"})
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	l.ID = old.ID
+	l.CourseID = old.CourseID
+	l.CreatedAt = old.CreatedAt
+	errs, err := s.validateLesson(ctx, &l)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	if _, err := s.lessons.ReplaceOne(ctx, bson.M{"_id": id}, l); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (s *Server) handleAdminDeleteLesson(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var l Lesson
+	if !findOne(ctx, w, s.lessons, id, "Lesson", &l) {
+		return
+	}
+	if err := s.deleteLessons(ctx, bson.M{"_id": id}); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
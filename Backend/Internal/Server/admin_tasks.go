@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// displayOrder is the order students see tasks, courses, modules and
+// lessons in.
+var displayOrder = bson.D{{Key: "order", Value: 1}, {Key: "_id", Value: 1}}
+
+// validateTask checks the fields an admin can set and returns problems
+// keyed by JSON field name, or nil when the task is valid. An empty status
+// is left for the caller to fill in: draft for new tasks, the stored
+// status for existing ones.
+func (s *Store) validateTask(ctx context.Context, t *Task) (map[string]string, error) {
+	errs := map[string]string{}
+
+	t.Title = strings.TrimSpace(t.Title)
+	t.Slug = strings.TrimSpace(t.Slug)
+
+	if t.Title == "" {
+		errs["title"] = "required"
+	} else if len(t.Title) > 200 {
+		errs["title"] = "must be at most 200 characters"
+	}
+	if t.Slug != "" && !slugRegex.MatchString(t.Slug) {
+		errs["slug"] = "must be lowercase letters and digits joined by dashes"
+	}
+	if strings.TrimSpace(t.Description) == "" {
+		errs["description"] = "required"
+	}
+	if len(t.Tags) > maxTaskTags {
+		errs["tags"] = fmt.Sprintf("must have at most %d tags", maxTaskTags)
+	} else if len(t.Tags) > 0 {
+		taxonomy, err := s.taskTags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for i, tag := range t.Tags {
+			switch {
+			case !taxonomy.has(tag):
+				errs[fmt.Sprintf("tags[%d]", i)] = "is not in the tag taxonomy"
+			case seen[tag]:
+				errs[fmt.Sprintf("tags[%d]", i)] = "duplicate"
+			}
+			seen[tag] = true
+		}
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	switch t.Difficulty {
+	case "", difficultyEasy, difficultyMedium, difficultyHard:
+	default:
+		errs["difficulty"] = "must be easy, medium or hard"
+	}
+	switch t.Status {
+	case "", taskDraft, taskPublished, taskArchived:
+	default:
+		errs["status"] = "must be draft, published or archived"
+	}
+	if t.Order < 0 {
+		errs["order"] = "must not be negative"
+	}
+	if t.GoVersion != "" {
+		ts, err := s.toolchains(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ts.allows(t.GoVersion) {
+			errs["goVersion"] = "is not in the toolchain allowlist"
+		}
+	}
+	validateHints(t.Hints, errs)
+	if err := validateFiles(t.Solution); err != nil {
+		errs["solution"] = err.Error()
+	}
+	if len(t.Editorial) > maxEditorialBytes {
+		errs["editorial"] = fmt.Sprintf("must be at most %d bytes", maxEditorialBytes)
+	}
+	if t.RevealAfter < 0 {
+		errs["revealAfter"] = "must not be negative"
+	}
+	if err := validateFiles(t.StarterFiles); err != nil {
+		errs["starterFiles"] = err.Error()
+	}
+	if err := validateFiles(t.Tests); err != nil {
+		errs["tests"] = err.Error()
+	}
+	for i, f := range t.Tests {
+		if !validTestFile(f.Path) {
+			errs[fmt.Sprintf("tests[%d].path", i)] = "must be a *_test.go file"
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	return errs, nil
+}
+
+// writeValidationErrors answers 422 with field-level errors.
+func writeValidationErrors(w http.ResponseWriter, errs map[string]string) {
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": errs})
+}
+
+// nextTaskOrder places a new task after all existing ones.
+func (s *Store) nextTaskOrder(ctx context.Context) (int, error) {
+	var last Task
+	err := s.tasks.FindOne(ctx, bson.M{},
+		options.FindOne().SetSort(bson.M{"order": -1}).SetProjection(bson.M{"order": 1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return last.Order + 1, err
+}
+
+// findTaskParam loads the task named by the {id} URL parameter, writing
+// 400/404/500 when it cannot.
+func (s *Server) findTaskParam(ctx context.Context, w http.ResponseWriter, r *http.Request) (Task, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return Task{}, false
+	}
+
+	var task Task
+	if err := s.tasks.FindOne(ctx, bson.M{"_id": id}).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			return Task{}, false
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return Task{}, false
+	}
+	return task, true
+}
+
+func (s *Server) handleAdminListTasks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if st := r.URL.Query().Get("status"); st != "" {
+		filter["status"] = st
+	}
+
+	cur, err := s.tasks.Find(ctx, filter, options.Find().SetSort(displayOrder))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	tasks := []Task{}
+	if err := cur.All(ctx, &tasks); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *Server) handleAdminGetTask(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findTaskParam(ctx, w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// handleAdminCreateTask adds a task, placing it after all existing ones
+// unless the request gives an order.
+func (s *Server) handleAdminCreateTask(w http.ResponseWriter, r *http.Request) {
+	var req taskReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task := req.withDefaults(Task{})
+	errs, err := s.validateTask(ctx, &task)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	if req.Order == nil {
+		if task.Order, err = s.nextTaskOrder(ctx); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if task.Status == "" {
+		task.Status = taskDraft
+	}
+	task.ID = primitive.NewObjectID()
+	task.CreatedAt = time.Now().UTC()
+	task.UpdatedAt = task.CreatedAt
+
+	if _, err := s.tasks.InsertOne(ctx, task); err != nil {
+		writeSlugWriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, task)
+}
+
+// writeSlugWriteError reports a failed insert or replace of a document
+// with a unique slug, turning a collision into a field error.
+func writeSlugWriteError(w http.ResponseWriter, err error) {
+	if mongo.IsDuplicateKeyError(err) {
+		writeValidationErrors(w, map[string]string{"slug": "already in use"})
+		return
+	}
+	http.Error(w, "Database error", http.StatusInternalServerError)
+}
+
+// withDefaults returns the requested task, taking the slug and order from
+// old where the request leaves them out.
+func (req taskReq) withDefaults(old Task) Task {
+	t := req.Task
+	t.Slug, t.Order = old.Slug, old.Order
+	if req.Slug != nil {
+		t.Slug = *req.Slug
+	}
+	if req.Order != nil {
+		t.Order = *req.Order
+	}
+	return t
+}
+
+// handleAdminUpdateTask replaces every editable field of a task. A slug
+// or order the request leaves out is kept.
+func (s *Server) handleAdminUpdateTask(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	old, ok := s.findTaskParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var req taskReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	task := req.withDefaults(old)
+	errs, err := s.validateTask(ctx, &task)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	if task.Status == "" {
+		task.Status = old.Status
+	}
+	task.ID = old.ID
+	task.CreatedAt = old.CreatedAt
+	task.UpdatedAt = time.Now().UTC()
+
+	if _, err := s.tasks.ReplaceOne(ctx, bson.M{"_id": old.ID}, task); err != nil {
+		writeSlugWriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// handleAdminDuplicateTask copies a task into a new draft at the end of
+// the list.
+func (s *Server) handleAdminDuplicateTask(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findTaskParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	order, err := s.nextTaskOrder(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	task.ID = primitive.NewObjectID()
+	task.Title += " (copy)"
+	task.Slug = ""
+	task.Status = taskDraft
+	task.Order = order
+	task.CreatedAt = time.Now().UTC()
+	task.UpdatedAt = task.CreatedAt
+
+	if _, err := s.tasks.InsertOne(ctx, task); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, task)
+}
+
+// handleAdminReorderTasks sets each listed task's order to its index.
+// Tasks not listed keep their current order.
+func (s *Server) handleAdminReorderTasks(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	models := make([]mongo.WriteModel, 0, len(req.IDs))
+	for i, idStr := range req.IDs {
+		id, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			writeValidationErrors(w, map[string]string{fmt.Sprintf("ids[%d]", i): "invalid task id"})
+			return
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": bson.M{"order": i}}))
+	}
+	if len(models) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.tasks.BulkWrite(ctx, models); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminDeleteTask removes a task and takes it out of every lesson.
+func (s *Server) handleAdminDeleteTask(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.tasks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		http.Error(w, "Delete failed", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.lessons.UpdateMany(ctx,
+		bson.M{"taskIds": id},
+		bson.M{"$pull": bson.M{"taskIds": id}},
+	); err != nil {
+		http.Error(w, "Delete failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
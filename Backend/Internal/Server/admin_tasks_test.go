@@ -0,0 +1,31 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTaskReqWithDefaults(t *testing.T) {
+	old := Task{Slug: "two-sum", Order: 7, Title: "Old"}
+
+	tests := []struct {
+		body  string
+		slug  string
+		order int
+	}{
+		{`{"title":"New"}`, "two-sum", 7},
+		{`{"title":"New","order":0}`, "two-sum", 0},
+		{`{"title":"New","slug":"","order":3}`, "", 3},
+		{`{"title":"New","slug":"sum-of-two"}`, "sum-of-two", 7},
+	}
+	for _, tt := range tests {
+		var req taskReq
+		if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		got := req.withDefaults(old)
+		if got.Title != "New" || got.Slug != tt.slug || got.Order != tt.order {
+			t.Errorf("%s: title %q, slug %q, order %d; want New, %q, %d", tt.body, got.Title, got.Slug, got.Order, tt.slug, tt.order)
+		}
+	}
+}
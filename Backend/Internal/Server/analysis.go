@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diagLine matches compiler, gofmt and vet positions. Paths come back
+// relative (./main.go), absolute inside the container (/work/main.go) or
+// absolute on the host for the local runner.
+var diagLine = regexp.MustCompile(`^(vet: )?(?:.*/work/|\./)?([^\s:]+\.go):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// parseDiagnostics extracts file:line:col diagnostics from tool output.
+// Lines without a position are ignored.
+func parseDiagnostics(out, severity string) []Diagnostic {
+	diags := []Diagnostic{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		m := diagLine.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[3])
+		col, _ := strconv.Atoi(m[4])
+		sev := severity
+		if m[1] != "" {
+			// vet reports type-checking failures with a "vet:" prefix.
+			sev = "error"
+		}
+		diags = append(diags, Diagnostic{
+			File:     m[2],
+			Line:     line,
+			Column:   col,
+			Severity: sev,
+			Message:  m[5],
+		})
+	}
+	return diags
+}
+
+// runTool runs a single analysis job for the current user through the
+// queue and writes any failure to w. ok is false when w has been used.
+func (s *Server) runTool(w http.ResponseWriter, r *http.Request, job RunJob) (RunResp, bool) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ticket, err := s.queue.enqueue(u.ID)
+	if err != nil {
+		writeQueueError(w, err)
+		return RunResp{}, false
+	}
+
+	resp, _, err := s.runQueued(r.Context(), ticket, job)
+	if err != nil {
+		log.Printf("%s failed: %v", job.Cmd[0], err)
+		http.Error(w, "run failed", http.StatusInternalServerError)
+		return RunResp{}, false
+	}
+	return resp, true
+}
+
+func (s *Server) handleFormat(w http.ResponseWriter, r *http.Request) {
+	var req formatReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tool := "gofmt"
+	if req.Imports {
+		if s.goimports == "" {
+			http.Error(w, "goimports is not available", http.StatusNotImplemented)
+			return
+		}
+		tool = s.goimports
+	}
+	goVersion, err := s.runGoVersion(r.Context(), req.GoVersion, req.TaskID)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+
+	resp, ok := s.runTool(w, r, RunJob{
+		Files:     map[string]string{"main.go": req.Code},
+		Cmd:       []string{tool, "main.go"},
+		GoVersion: goVersion,
+	})
+	if !ok {
+		return
+	}
+
+	if resp.ExitCode != 0 {
+		writeJSON(w, http.StatusOK, FormatResp{
+			Code:        req.Code,
+			Diagnostics: parseDiagnostics(resp.Stderr, "error"),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, FormatResp{
+		Code:        resp.Stdout,
+		Changed:     resp.Stdout != req.Code,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *Server) handleVet(w http.ResponseWriter, r *http.Request) {
+	var req analysisReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	goVersion, err := s.runGoVersion(r.Context(), req.GoVersion, req.TaskID)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+	files, err := buildWorkspace(req.Code, req.Files, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, ok := s.runTool(w, r, RunJob{
+		Files:     files,
+		Cmd:       []string{"go", "vet", "./..."},
+		GoVersion: goVersion,
+	})
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DiagnosticsResp{
+		Diagnostics: parseDiagnostics(resp.Stderr, "warning"),
+	})
+}
+
+// staticcheckIssue is one line of `staticcheck -f json` output.
+type staticcheckIssue struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Location struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+	} `json:"location"`
+	Message string `json:"message"`
+}
+
+func (s *Server) handleLint(w http.ResponseWriter, r *http.Request) {
+	if s.staticcheck == "" {
+		http.Error(w, "Linting is not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	var req analysisReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	goVersion, err := s.runGoVersion(r.Context(), req.GoVersion, req.TaskID)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+	files, err := buildWorkspace(req.Code, req.Files, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, ok := s.runTool(w, r, RunJob{
+		Files:     files,
+		Cmd:       []string{s.staticcheck, "-f", "json", "./..."},
+		GoVersion: goVersion,
+	})
+	if !ok {
+		return
+	}
+
+	diags := []Diagnostic{}
+	sc := bufio.NewScanner(strings.NewReader(resp.Stdout))
+	for sc.Scan() {
+		var issue staticcheckIssue
+		if err := json.Unmarshal(sc.Bytes(), &issue); err != nil {
+			continue
+		}
+		file := issue.Location.File
+		if i := strings.LastIndex(file, "/work/"); i >= 0 {
+			file = file[i+len("/work/"):]
+		}
+		diags = append(diags, Diagnostic{
+			File:     file,
+			Line:     issue.Location.Line,
+			Column:   issue.Location.Column,
+			Severity: issue.Severity,
+			Message:  issue.Message,
+			Code:     issue.Code,
+		})
+	}
+	// Without JSON output staticcheck failed before analysing, usually on
+	// a compile error, which it prints in the usual position format.
+	if len(diags) == 0 && resp.ExitCode != 0 {
+		diags = parseDiagnostics(resp.Stderr, "error")
+	}
+
+	writeJSON(w, http.StatusOK, DiagnosticsResp{Diagnostics: diags})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -32,8 +33,8 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid email", http.StatusBadRequest)
 		return
 	}
-	if len(req.Password) < 6 {
-		http.Error(w, "Password must be at least 6 characters", http.StatusBadRequest)
+	if msg := passwordProblem(req.Password); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
@@ -43,12 +44,14 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now().UTC()
 	u := userDoc{
-		Name:      req.Name,
-		Email:     req.Email,
-		PassHash:  passHash,
-		Role:      "user",
-		CreatedAt: time.Now().UTC(),
+		Name:               req.Name,
+		Email:              req.Email,
+		VerificationSentAt: &now,
+		PassHash:           passHash,
+		Role:               "user",
+		CreatedAt:          now,
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -64,8 +67,12 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := res.InsertedID.(primitive.ObjectID)
-	if err := s.createSession(w, userID); err != nil {
+	u.ID = res.InsertedID.(primitive.ObjectID)
+	if err := s.sendVerification(ctx, u, u.Email); err != nil {
+		log.Printf("sending confirmation to new user %s failed: %v", u.ID.Hex(), err)
+	}
+
+	if err := s.createSession(w, r, u.ID); err != nil {
 		http.Error(w, "Created user, but session failed", http.StatusInternalServerError)
 		return
 	}
@@ -102,7 +109,16 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.createSession(w, u.ID); err != nil {
+	if u.TOTPEnabled {
+		if err := s.createPendingSession(w, r, u.ID); err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]bool{"twoFactorRequired": true})
+		return
+	}
+
+	if err := s.createSession(w, r, u.ID); err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
@@ -145,10 +161,13 @@ func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 	u := r.Context().Value(ctxUserKey{}).(userDoc)
 	writeJSON(w, http.StatusOK, meResp{
-		ID:    u.ID.Hex(),
-		Name:  u.Name,
-		Email: u.Email,
-		Role:  u.Role,
-		Photo: u.Photo,
+		ID:            u.ID.Hex(),
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		PendingEmail:  u.PendingEmail,
+		TwoFactor:     u.TOTPEnabled,
+		Role:          u.Role,
+		Photo:         u.Photo,
 	})
 }
@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+)
+
+// A task bundle is a directory holding one task:
+//
+//	task.yaml     metadata (taskManifest)
+//	README.md     description shown to students
+//	EDITORIAL.md  optional explanation shown with the solution
+//	starter/      starter files, paths relative to this directory
+//	tests/        hidden *_test.go files used for grading
+//	solution/     optional reference solution
+const (
+	bundleManifest  = "task.yaml"
+	bundleReadme    = "README.md"
+	bundleEditorial = "EDITORIAL.md"
+	bundleStarter   = "starter"
+	bundleTests     = "tests"
+	bundleSolution  = "solution"
+)
+
+var slugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type taskManifest struct {
+	Slug        string   `yaml:"slug"`
+	Title       string   `yaml:"title"`
+	Tags        []string `yaml:"tags,omitempty"`
+	Difficulty  string   `yaml:"difficulty,omitempty"`
+	Status      string   `yaml:"status,omitempty"`
+	Order       int      `yaml:"order,omitempty"`
+	GoVersion   string   `yaml:"goVersion,omitempty"`
+	Hints       []Hint   `yaml:"hints,omitempty"`
+	RevealAfter int      `yaml:"revealAfter,omitempty"`
+}
+
+// ImportResult counts what ImportTasks did with each bundle.
+type ImportResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+// slugify turns a title into a slug, e.g. "Hello, World!" -> "hello-world".
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// uniqueSlug returns base, or base-2, base-3, ... if it is taken.
+func uniqueSlug(base string, used map[string]bool) string {
+	if base == "" {
+		base = "task"
+	}
+	slug := base
+	for i := 2; used[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug
+}
+
+// readTaskBundle loads the task stored in dir.
+func readTaskBundle(dir string) (Task, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, bundleManifest))
+	if err != nil {
+		return Task{}, err
+	}
+	var m taskManifest
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return Task{}, fmt.Errorf("%s: %w", bundleManifest, err)
+	}
+
+	readme, err := os.ReadFile(filepath.Join(dir, bundleReadme))
+	if err != nil {
+		return Task{}, err
+	}
+
+	task := Task{
+		Slug:        m.Slug,
+		Title:       m.Title,
+		Tags:        m.Tags,
+		Difficulty:  m.Difficulty,
+		Description: string(readme),
+		Status:      m.Status,
+		Order:       m.Order,
+		GoVersion:   m.GoVersion,
+		Hints:       m.Hints,
+		RevealAfter: m.RevealAfter,
+	}
+	editorial, err := os.ReadFile(filepath.Join(dir, bundleEditorial))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Task{}, err
+	}
+	task.Editorial = string(editorial)
+	if task.StarterFiles, err = readBundleFiles(filepath.Join(dir, bundleStarter)); err != nil {
+		return Task{}, err
+	}
+	if task.Tests, err = readBundleFiles(filepath.Join(dir, bundleTests)); err != nil {
+		return Task{}, err
+	}
+	if task.Solution, err = readBundleFiles(filepath.Join(dir, bundleSolution)); err != nil {
+		return Task{}, err
+	}
+	return task, nil
+}
+
+// readBundleFiles returns every regular file under dir with slash-separated
+// paths relative to it. A missing dir has no files.
+func readBundleFiles(dir string) ([]SourceFile, error) {
+	files := []SourceFile{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, SourceFile{Path: filepath.ToSlash(rel), Content: string(content)})
+		return nil
+	})
+	return files, err
+}
+
+// writeTaskBundle stores t in dir, replacing any starter and test files
+// left over from an earlier export.
+func writeTaskBundle(dir string, t Task) error {
+	raw, err := yaml.Marshal(taskManifest{
+		Slug:        t.Slug,
+		Title:       t.Title,
+		Tags:        t.Tags,
+		Difficulty:  t.Difficulty,
+		Status:      t.Status,
+		Order:       t.Order,
+		GoVersion:   t.GoVersion,
+		Hints:       t.Hints,
+		RevealAfter: t.RevealAfter,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, bundleManifest), raw, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, bundleReadme), []byte(t.Description), 0o644); err != nil {
+		return err
+	}
+	if err := writeOptionalFile(filepath.Join(dir, bundleEditorial), t.Editorial); err != nil {
+		return err
+	}
+	if err := writeBundleFiles(filepath.Join(dir, bundleStarter), t.StarterFiles); err != nil {
+		return err
+	}
+	if err := writeBundleFiles(filepath.Join(dir, bundleSolution), t.Solution); err != nil {
+		return err
+	}
+	return writeBundleFiles(filepath.Join(dir, bundleTests), t.Tests)
+}
+
+// writeOptionalFile writes content to path, or removes path when there is
+// no content.
+func writeOptionalFile(path, content string) error {
+	if content == "" {
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func writeBundleFiles(dir string, files []SourceFile) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for _, f := range files {
+		rel := filepath.FromSlash(f.Path)
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("%s: path escapes the bundle", f.Path)
+		}
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findTaskBundles returns every directory under root that holds a
+// task.yaml. Bundles do not nest.
+func findTaskBundles(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(path, bundleManifest)); err == nil {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// sameTaskContent reports whether replacing a with b would change anything
+// but timestamps.
+func sameTaskContent(a, b Task) bool {
+	norm := func(t Task) Task {
+		t.ID = primitive.NilObjectID
+		t.CreatedAt, t.UpdatedAt = time.Time{}, time.Time{}
+		if len(t.StarterFiles) == 0 {
+			t.StarterFiles = nil
+		}
+		if len(t.Tests) == 0 {
+			t.Tests = nil
+		}
+		if len(t.Hints) == 0 {
+			t.Hints = nil
+		}
+		if len(t.Solution) == 0 {
+			t.Solution = nil
+		}
+		return t
+	}
+	return reflect.DeepEqual(norm(a), norm(b))
+}
+
+// formatValidationErrors renders field errors on one line, sorted by field.
+func formatValidationErrors(errs map[string]string) string {
+	fields := make([]string, 0, len(errs))
+	for f := range errs {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f + " " + errs[f]
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ImportTasks upserts every task bundle under root into the tasks
+// collection, matching existing tasks by slug. All bundles are validated
+// before anything is written, so a bad tree changes nothing, and importing
+// the same tree twice leaves the second run with nothing to do.
+func (s *Store) ImportTasks(ctx context.Context, root string) (ImportResult, error) {
+	var res ImportResult
+
+	dirs, err := findTaskBundles(root)
+	if err != nil {
+		return res, err
+	}
+
+	tasks := make([]Task, 0, len(dirs))
+	seen := map[string]string{}
+	for _, dir := range dirs {
+		t, err := readTaskBundle(dir)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", dir, err)
+		}
+		if t.Slug == "" {
+			return res, fmt.Errorf("%s: %s: slug required", dir, bundleManifest)
+		}
+		if other, ok := seen[t.Slug]; ok {
+			return res, fmt.Errorf("%s: slug %q is also used by %s", dir, t.Slug, other)
+		}
+		seen[t.Slug] = dir
+
+		errs, err := s.validateTask(ctx, &t)
+		if err != nil {
+			return res, err
+		}
+		if errs != nil {
+			return res, fmt.Errorf("%s: %s", dir, formatValidationErrors(errs))
+		}
+		tasks = append(tasks, t)
+	}
+
+	for _, t := range tasks {
+		var old Task
+		err := s.tasks.FindOne(ctx, bson.M{"slug": t.Slug}).Decode(&old)
+		if err == mongo.ErrNoDocuments {
+			if t.Order == 0 {
+				if t.Order, err = s.nextTaskOrder(ctx); err != nil {
+					return res, err
+				}
+			}
+			if t.Status == "" {
+				t.Status = taskDraft
+			}
+			t.ID = primitive.NewObjectID()
+			t.CreatedAt = time.Now().UTC()
+			t.UpdatedAt = t.CreatedAt
+			if _, err := s.tasks.InsertOne(ctx, t); err != nil {
+				return res, fmt.Errorf("%s: %w", t.Slug, err)
+			}
+			res.Created++
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+
+		if t.Order == 0 {
+			t.Order = old.Order
+		}
+		if t.Status == "" {
+			t.Status = old.Status
+		}
+		if sameTaskContent(old, t) {
+			res.Unchanged++
+			continue
+		}
+		t.ID = old.ID
+		t.CreatedAt = old.CreatedAt
+		t.UpdatedAt = time.Now().UTC()
+		if _, err := s.tasks.ReplaceOne(ctx, bson.M{"_id": old.ID}, t); err != nil {
+			return res, fmt.Errorf("%s: %w", t.Slug, err)
+		}
+		res.Updated++
+	}
+	return res, nil
+}
+
+// ExportTasks writes every task to root/<slug> and returns how many it
+// wrote. Tasks created through the admin API may have no slug yet; they
+// get one derived from their title, saved so that importing the export
+// updates them in place.
+func (s *Store) ExportTasks(ctx context.Context, root string) (int, error) {
+	cur, err := s.tasks.Find(ctx, bson.M{}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		return 0, err
+	}
+	var tasks []Task
+	if err := cur.All(ctx, &tasks); err != nil {
+		return 0, err
+	}
+
+	used := map[string]bool{}
+	for _, t := range tasks {
+		used[t.Slug] = true
+	}
+
+	for _, t := range tasks {
+		if t.Slug == "" {
+			t.Slug = uniqueSlug(slugify(t.Title), used)
+			used[t.Slug] = true
+			_, err := s.tasks.UpdateOne(ctx, bson.M{"_id": t.ID}, bson.M{"$set": bson.M{"slug": t.Slug}})
+			if err != nil {
+				return 0, err
+			}
+		}
+		if err := writeTaskBundle(filepath.Join(root, t.Slug), t); err != nil {
+			return 0, fmt.Errorf("%s: %w", t.Slug, err)
+		}
+	}
+	return len(tasks), nil
+}
@@ -4,8 +4,40 @@ import "time"
 
 const (
 	cookieName       = "goedu_session"
-	sessionTTL       = 7 * 24 * time.Hour
+	sessionTTL       = 7 * 24 * time.Hour  // idle
+	sessionMaxAge    = 30 * 24 * time.Hour // absolute
+	sessionTouchGap  = time.Minute         // least time between lastSeenAt writes
+	maxUserAgentLen  = 300
+	securityLogLimit = 50
 	loginRateWindow  = 1 * time.Minute
 	loginRateMaxHits = 10
 	bcryptCost       = 12
+	minPasswordLen   = 6
+	maxPasswordBytes = 72 // bcrypt ignores anything longer
+	passwordResetTTL = time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+	verifyResendWait = time.Minute // between confirmation mails to one user
+	pendingLoginTTL  = 5 * time.Minute
+	max2FATries      = 5
+	numRecoveryCodes = 10
+	oauthStateTTL    = 10 * time.Minute
+	runTimeout       = 10 * time.Second
+	maxStdinBytes    = 1 << 20
+	queueRetryAfter  = 5 * time.Second
+
+	taskDraft     = "draft"
+	taskPublished = "published"
+	taskArchived  = "archived"
+
+	difficultyEasy   = "easy"
+	difficultyMedium = "medium"
+	difficultyHard   = "hard"
+
+	maxTaskTags     = 10
+	maxTaskHints    = 10
+	taskPageSize    = 20
+	maxTaskPageSize = 100
+
+	defaultRevealAfter = 3
+	maxEditorialBytes  = 64 << 10
 )
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loadCourseTree returns course with its modules and lessons in order,
+// plus the flat lesson list for lock computations.
+func (s *Server) loadCourseTree(ctx context.Context, course Course) (CourseTree, []Lesson, error) {
+	tree := CourseTree{Course: course, Modules: []ModuleNode{}}
+
+	cur, err := s.modules.Find(ctx, bson.M{"courseId": course.ID}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		return tree, nil, err
+	}
+	var modules []Module
+	if err := cur.All(ctx, &modules); err != nil {
+		return tree, nil, err
+	}
+
+	cur, err = s.lessons.Find(ctx, bson.M{"courseId": course.ID}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		return tree, nil, err
+	}
+	var lessons []Lesson
+	if err := cur.All(ctx, &lessons); err != nil {
+		return tree, nil, err
+	}
+
+	byModule := map[primitive.ObjectID][]LessonNode{}
+	for _, l := range lessons {
+		byModule[l.ModuleID] = append(byModule[l.ModuleID], LessonNode{Lesson: l})
+	}
+	for _, m := range modules {
+		nodes := byModule[m.ID]
+		if nodes == nil {
+			nodes = []LessonNode{}
+		}
+		tree.Modules = append(tree.Modules, ModuleNode{Module: m, Lessons: nodes})
+	}
+	return tree, lessons, nil
+}
+
+// completedLessons returns which of lessonIDs userID has completed.
+func (s *Server) completedLessons(ctx context.Context, userID primitive.ObjectID, lessonIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	done := map[primitive.ObjectID]bool{}
+	if len(lessonIDs) == 0 {
+		return done, nil
+	}
+
+	cur, err := s.completions.Find(ctx, bson.M{
+		"userId":   userID,
+		"lessonId": bson.M{"$in": lessonIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var docs []lessonCompletionDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		done[d.LessonID] = true
+	}
+	return done, nil
+}
+
+// lessonLocked reports whether any prerequisite of l is not yet done.
+func lessonLocked(l Lesson, done map[primitive.ObjectID]bool) bool {
+	for _, p := range l.Prerequisites {
+		if !done[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// lockedBy reports whether content placed in lessons is locked: it is when
+// it belongs to at least one lesson and all of them are locked. Content in
+// no published lesson is open to everyone.
+func lockedBy(lessons []Lesson, done map[primitive.ObjectID]bool) bool {
+	for _, l := range lessons {
+		if !lessonLocked(l, done) {
+			return false
+		}
+	}
+	return len(lessons) > 0
+}
+
+// lessonEarned reports whether l completes now that passed of its tasks
+// have been passed, given the caller's completed lessons.
+func lessonEarned(l Lesson, passed int, done map[primitive.ObjectID]bool) bool {
+	return passed >= len(l.TaskIDs) && !lessonLocked(l, done)
+}
+
+// publishedLessons returns the lessons of published courses matching
+// filter, along with the caller's completions of their prerequisites.
+func (s *Server) publishedLessons(ctx context.Context, userID primitive.ObjectID, filter bson.M) ([]Lesson, map[primitive.ObjectID]bool, error) {
+	courseIDs, err := s.courses.Distinct(ctx, "_id", bson.M{"status": taskPublished})
+	if err != nil {
+		return nil, nil, err
+	}
+	filter["courseId"] = bson.M{"$in": courseIDs}
+
+	cur, err := s.lessons.Find(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	var lessons []Lesson
+	if err := cur.All(ctx, &lessons); err != nil {
+		return nil, nil, err
+	}
+
+	var prereqs []primitive.ObjectID
+	for _, l := range lessons {
+		prereqs = append(prereqs, l.Prerequisites...)
+	}
+	done, err := s.completedLessons(ctx, userID, prereqs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lessons, done, nil
+}
+
+// lockedTasks returns those of taskIDs that u may not open yet because
+// every lesson they belong to is locked. Admins see everything.
+func (s *Server) lockedTasks(ctx context.Context, u userDoc, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	locked := map[primitive.ObjectID]bool{}
+	if u.Role == "admin" || len(taskIDs) == 0 {
+		return locked, nil
+	}
+
+	lessons, done, err := s.publishedLessons(ctx, u.ID, bson.M{"taskIds": bson.M{"$in": taskIDs}})
+	if err != nil {
+		return nil, err
+	}
+	byTask := map[primitive.ObjectID][]Lesson{}
+	for _, l := range lessons {
+		for _, id := range l.TaskIDs {
+			byTask[id] = append(byTask[id], l)
+		}
+	}
+	for _, id := range taskIDs {
+		if lockedBy(byTask[id], done) {
+			locked[id] = true
+		}
+	}
+	return locked, nil
+}
+
+// handbookLocked reports whether u may not read the handbook page slug
+// yet because every lesson it belongs to is locked.
+func (s *Server) handbookLocked(ctx context.Context, u userDoc, slug string) (bool, error) {
+	if u.Role == "admin" {
+		return false, nil
+	}
+	lessons, done, err := s.publishedLessons(ctx, u.ID, bson.M{"handbookPages": slug})
+	if err != nil {
+		return false, err
+	}
+	return lockedBy(lessons, done), nil
+}
+
+func (s *Server) handleListCourses(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.courses.Find(ctx, bson.M{"status": taskPublished}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	courses := []Course{}
+	if err := cur.All(ctx, &courses); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, courses)
+}
+
+// handleGetCourse returns a published course as a tree annotated with the
+// caller's completion and lock state. Locked lessons do not reveal their
+// tasks or handbook pages.
+func (s *Server) handleGetCourse(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid course id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var course Course
+	err = s.courses.FindOne(ctx, bson.M{"_id": id, "status": taskPublished}).Decode(&course)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	tree, lessons, err := s.loadCourseTree(ctx, course)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(lessons))
+	for _, l := range lessons {
+		ids = append(ids, l.ID)
+	}
+	done, err := s.completedLessons(ctx, u.ID, ids)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	for mi := range tree.Modules {
+		for li := range tree.Modules[mi].Lessons {
+			n := &tree.Modules[mi].Lessons[li]
+			n.Completed = done[n.ID]
+			n.Locked = lessonLocked(n.Lesson, done)
+			if n.Locked {
+				n.TaskIDs = []primitive.ObjectID{}
+				n.HandbookPages = []string{}
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, tree)
+}
+
+// handleCompleteLesson marks an unlocked reading lesson of a published
+// course as completed for the caller. Lessons with tasks complete on their
+// own once every task has passed.
+func (s *Server) handleCompleteLesson(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "lessonId"))
+	if err != nil {
+		http.Error(w, "Invalid lesson id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var lesson Lesson
+	if err := s.lessons.FindOne(ctx, bson.M{"_id": id}).Decode(&lesson); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	n, err := s.courses.CountDocuments(ctx, bson.M{"_id": lesson.CourseID, "status": taskPublished})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return
+	}
+
+	if len(lesson.TaskIDs) > 0 {
+		http.Error(w, "Lesson is completed by passing its tasks", http.StatusConflict)
+		return
+	}
+
+	done, err := s.completedLessons(ctx, u.ID, lesson.Prerequisites)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if lessonLocked(lesson, done) {
+		http.Error(w, "Lesson is locked", http.StatusForbidden)
+		return
+	}
+
+	res, err := s.completions.UpdateOne(ctx,
+		bson.M{"userId": u.ID, "lessonId": lesson.ID},
+		bson.M{"$setOnInsert": bson.M{"completedAt": time.Now().UTC()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.UpsertedCount > 0 {
+		// Lessons waiting only on this one can now complete.
+		next, err := s.dependentTaskLessons(ctx, lesson.ID)
+		if err == nil {
+			err = s.completeEarnedLessons(ctx, u.ID, next)
+		}
+		if err != nil {
+			log.Printf("completing lessons after %s failed: %v", lesson.ID.Hex(), err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLessonLocks(t *testing.T) {
+	intro := Lesson{ID: primitive.NewObjectID()}
+	basics := Lesson{ID: primitive.NewObjectID(), Prerequisites: []primitive.ObjectID{intro.ID}}
+	review := Lesson{ID: primitive.NewObjectID()}
+
+	none := map[primitive.ObjectID]bool{}
+	introDone := map[primitive.ObjectID]bool{intro.ID: true}
+
+	if lessonLocked(intro, none) || !lessonLocked(basics, none) || lessonLocked(basics, introDone) {
+		t.Error("lessonLocked does not follow prerequisites")
+	}
+
+	tests := []struct {
+		name    string
+		lessons []Lesson
+		done    map[primitive.ObjectID]bool
+		want    bool
+	}{
+		{"in no lesson", nil, none, false},
+		{"only in a locked lesson", []Lesson{basics}, none, true},
+		{"also in an open lesson", []Lesson{basics, review}, none, false},
+		{"prerequisite done", []Lesson{basics}, introDone, false},
+	}
+	for _, tt := range tests {
+		if got := lockedBy(tt.lessons, tt.done); got != tt.want {
+			t.Errorf("%s: lockedBy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLessonEarnedWaitsForPrerequisites(t *testing.T) {
+	intro := Lesson{ID: primitive.NewObjectID()}
+	loops := Lesson{
+		ID:            primitive.NewObjectID(),
+		TaskIDs:       []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()},
+		Prerequisites: []primitive.ObjectID{intro.ID},
+	}
+
+	if lessonEarned(loops, 1, map[primitive.ObjectID]bool{intro.ID: true}) {
+		t.Error("completed with a task still unpassed")
+	}
+	if lessonEarned(loops, 2, map[primitive.ObjectID]bool{}) {
+		t.Error("completed while its prerequisite is not done")
+	}
+	if !lessonEarned(loops, 2, map[primitive.ObjectID]bool{intro.ID: true}) {
+		t.Error("not completed with every task passed and its prerequisite done")
+	}
+}
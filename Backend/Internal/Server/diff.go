@@ -0,0 +1,255 @@
+package server
+
+import "strings"
+
+const (
+	diffContext = 3
+	// maxDiffLines and maxDiffEdits bound the work and memory of diffLines,
+	// which keeps O(D²) of trace for D edits.
+	maxDiffLines = 4000
+	maxDiffEdits = 1000
+)
+
+// noEOLOp marks the line after a changed last line that has no newline,
+// as "\ No newline at end of file" does in unified diffs.
+const noEOLOp = "\\"
+
+type DiffLine struct {
+	Op   string `json:"op"` // " ", "+", "-" or noEOLOp
+	Text string `json:"text"`
+}
+
+type DiffHunk struct {
+	OldStart int        `json:"oldStart"`
+	OldLines int        `json:"oldLines"`
+	NewStart int        `json:"newStart"`
+	NewLines int        `json:"newLines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+type FileDiff struct {
+	Path   string     `json:"path"`
+	Status string     `json:"status"` // added, removed or modified
+	Hunks  []DiffHunk `json:"hunks"`
+}
+
+// diffFiles compares two file trees and returns a diff for every file that
+// was added, removed or changed, in path order of the newer tree first.
+func diffFiles(from, to []SourceFile) []FileDiff {
+	old := make(map[string]string, len(from))
+	for _, f := range from {
+		old[f.Path] = f.Content
+	}
+
+	diffs := []FileDiff{}
+	seen := make(map[string]bool, len(to))
+	for _, f := range to {
+		seen[f.Path] = true
+		prev, ok := old[f.Path]
+		switch {
+		case !ok:
+			diffs = append(diffs, FileDiff{Path: f.Path, Status: "added", Hunks: diffText("", f.Content)})
+		case prev != f.Content:
+			diffs = append(diffs, FileDiff{Path: f.Path, Status: "modified", Hunks: diffText(prev, f.Content)})
+		}
+	}
+	for _, f := range from {
+		if !seen[f.Path] {
+			diffs = append(diffs, FileDiff{Path: f.Path, Status: "removed", Hunks: diffText(f.Content, "")})
+		}
+	}
+	return diffs
+}
+
+// diffText returns unified-diff style hunks turning a into b. A file
+// that only gains or loses its final newline still gets a hunk.
+func diffText(a, b string) []DiffHunk {
+	return hunks(noEOLMarkers(diffLines(eolLines(a), eolLines(b))))
+}
+
+// eolLines splits s like splitLines, but ends a last line that has no
+// newline with "\n", which no split line otherwise contains, so it differs
+// from the same line with one.
+func eolLines(s string) []string {
+	lines := splitLines(s)
+	if len(lines) > 0 && !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
+
+// noEOLMarkers strips the "\n" eolLines adds and follows a changed line
+// that had it with a noEOLOp line.
+func noEOLMarkers(lines []DiffLine) []DiffLine {
+	out := make([]DiffLine, 0, len(lines)+2)
+	for _, l := range lines {
+		text, noEOL := strings.CutSuffix(l.Text, "\n")
+		l.Text = text
+		out = append(out, l)
+		if noEOL && l.Op != " " {
+			out = append(out, DiffLine{Op: noEOLOp, Text: "No newline at end of file"})
+		}
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// replaceLines is the edit script removing every line of a and adding
+// every line of b.
+func replaceLines(a, b []string) []DiffLine {
+	out := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a {
+		out = append(out, DiffLine{Op: "-", Text: l})
+	}
+	for _, l := range b {
+		out = append(out, DiffLine{Op: "+", Text: l})
+	}
+	return out
+}
+
+// diffLines is Myers' O(ND) shortest edit script. Inputs too large, or too
+// different, to trace cheaply degrade to replaceLines.
+func diffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n+m > maxDiffLines {
+		return replaceLines(a, b)
+	}
+
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[-d..d] as it was before step d; step d only reads
+	// diagonals within d-1 of the centre, so that window is enough.
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var rev []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			rev = append(rev, DiffLine{Op: " ", Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			rev = append(rev, DiffLine{Op: "+", Text: b[y-1]})
+		} else {
+			rev = append(rev, DiffLine{Op: "-", Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, DiffLine{Op: " ", Text: a[x-1]})
+		x--
+		y--
+	}
+
+	out := make([]DiffLine, len(rev))
+	for i, l := range rev {
+		out[len(rev)-1-i] = l
+	}
+	return out
+}
+
+// hunks groups an edit script into hunks with diffContext unchanged lines
+// around each change. Line numbers are 1-based, as in unified diffs.
+func hunks(lines []DiffLine) []DiffHunk {
+	out := []DiffHunk{}
+
+	// oldAt[i]/newAt[i] are the line numbers reached before lines[i].
+	oldAt := make([]int, len(lines)+1)
+	newAt := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if l.Op == " " || l.Op == "-" {
+			oldAt[i+1]++
+		}
+		if l.Op == " " || l.Op == "+" {
+			newAt[i+1]++
+		}
+	}
+
+	i := 0
+	for i < len(lines) {
+		if lines[i].Op == " " {
+			i++
+			continue
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != " " {
+				end++
+				continue
+			}
+			// Merge with the next change if it is within two contexts.
+			run := end
+			for run < len(lines) && lines[run].Op == " " {
+				run++
+			}
+			if run < len(lines) && run-end <= 2*diffContext {
+				end = run
+				continue
+			}
+			end += diffContext
+			if end > len(lines) {
+				end = len(lines)
+			}
+			break
+		}
+
+		h := DiffHunk{
+			OldStart: oldAt[start] + 1,
+			OldLines: oldAt[end] - oldAt[start],
+			NewStart: newAt[start] + 1,
+			NewLines: newAt[end] - newAt[start],
+			Lines:    lines[start:end],
+		}
+		out = append(out, h)
+		i = end
+	}
+	return out
+}
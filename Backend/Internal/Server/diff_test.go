@@ -0,0 +1,216 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyDiff rebuilds both sides of an edit script, to check it is a valid
+// transformation.
+func applyDiff(lines []DiffLine) (a, b []string) {
+	for _, l := range lines {
+		if l.Op != "+" {
+			a = append(a, l.Text)
+		}
+		if l.Op != "-" {
+			b = append(b, l.Text)
+		}
+	}
+	return a, b
+}
+
+func numbered(prefix string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return out
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a\nb\nc", "a\nb\nc", 0},
+		{"a\nb\nc", "a\nc", 1},
+		{"a\nc", "a\nb\nc", 1},
+		{"a\nb\nc", "a\nx\nc", 2},
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 5}, // Myers' paper example
+	}
+	for _, tt := range tests {
+		a, b := splitLines(tt.a), splitLines(tt.b)
+		lines := diffLines(a, b)
+		gotA, gotB := applyDiff(lines)
+		if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+			t.Errorf("diffLines(%q, %q) does not rebuild its inputs: %v", tt.a, tt.b, lines)
+		}
+		edits := 0
+		for _, l := range lines {
+			if l.Op != " " {
+				edits++
+			}
+		}
+		if edits != tt.edits {
+			t.Errorf("diffLines(%q, %q) made %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}
+
+func TestDiffLinesLimits(t *testing.T) {
+	// Nothing in common: more edits than maxDiffEdits.
+	a, b := numbered("a", 800), numbered("b", 800)
+	lines := diffLines(a, b)
+	if !reflect.DeepEqual(lines, replaceLines(a, b)) {
+		t.Error("distant inputs should degrade to replaceLines")
+	}
+
+	// Too many lines, however similar.
+	a = numbered("x", maxDiffLines)
+	b = append(append([]string(nil), a...), "tail")
+	if got := len(diffLines(a, b)); got != len(a)+len(b) {
+		t.Errorf("oversized inputs gave %d lines, want %d", got, len(a)+len(b))
+	}
+
+	// Large but close inputs still get a real diff.
+	a = numbered("y", maxDiffLines/2-1)
+	b = append([]string(nil), a...)
+	b[1000] = "changed"
+	edits := 0
+	for _, l := range diffLines(a, b) {
+		if l.Op != " " {
+			edits++
+		}
+	}
+	if edits != 2 {
+		t.Errorf("one changed line gave %d edits, want 2", edits)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	old := numbered("l", 30)
+
+	// Changes 5 lines apart share a hunk; 20 lines apart they do not.
+	near := append([]string(nil), old...)
+	near[10], near[15] = "x", "y"
+	hs := diffText(strings.Join(old, "\n"), strings.Join(near, "\n"))
+	if len(hs) != 1 {
+		t.Fatalf("near changes gave %d hunks, want 1", len(hs))
+	}
+	h := hs[0]
+	if h.OldStart != 8 || h.OldLines != 12 || h.NewStart != 8 || h.NewLines != 12 {
+		t.Errorf("near hunk = -%d,%d +%d,%d, want -8,12 +8,12", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	}
+
+	far := append([]string(nil), old...)
+	far[2], far[25] = "x", "y"
+	hs = diffText(strings.Join(old, "\n"), strings.Join(far, "\n"))
+	if len(hs) != 2 {
+		t.Fatalf("far changes gave %d hunks, want 2", len(hs))
+	}
+	if hs[0].OldStart != 1 || hs[0].OldLines != 6 {
+		t.Errorf("first hunk = -%d,%d, want -1,6 (context clipped at the start)", hs[0].OldStart, hs[0].OldLines)
+	}
+	if hs[1].OldStart != 23 || hs[1].OldLines != 7 {
+		t.Errorf("second hunk = -%d,%d, want -23,7", hs[1].OldStart, hs[1].OldLines)
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	from := []SourceFile{
+		{Path: "main.go", Content: "package main\n\nfunc main() {}\n"},
+		{Path: "old.go", Content: "package main\n"},
+		{Path: "same.go", Content: "package main\n"},
+	}
+	to := []SourceFile{
+		{Path: "main.go", Content: "package main\n\nfunc main() { run() }\n"},
+		{Path: "new.go", Content: "package main\n\nfunc run() {}\n"},
+		{Path: "same.go", Content: "package main\n"},
+	}
+
+	diffs := diffFiles(from, to)
+	got := map[string]string{}
+	for _, d := range diffs {
+		got[d.Path] = d.Status
+	}
+	want := map[string]string{"main.go": "modified", "new.go": "added", "old.go": "removed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+
+	for _, d := range diffs {
+		if len(d.Hunks) != 1 {
+			t.Errorf("%s: %d hunks, want 1", d.Path, len(d.Hunks))
+			continue
+		}
+		h := d.Hunks[0]
+		switch d.Status {
+		case "added":
+			if h.OldLines != 0 || h.NewLines != 3 {
+				t.Errorf("added hunk = -%d +%d, want -0 +3", h.OldLines, h.NewLines)
+			}
+		case "removed":
+			if h.OldLines != 1 || h.NewLines != 0 {
+				t.Errorf("removed hunk = -%d +%d, want -1 +0", h.OldLines, h.NewLines)
+			}
+		}
+	}
+}
+
+func TestDiffTextNewlineAtEOF(t *testing.T) {
+	noEOL := DiffLine{Op: noEOLOp, Text: "No newline at end of file"}
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffHunk
+	}{
+		{
+			name: "newline added",
+			a:    "package main\n\nfunc main() {}",
+			b:    "package main\n\nfunc main() {}\n",
+			want: []DiffHunk{{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3, Lines: []DiffLine{
+				{Op: " ", Text: "package main"},
+				{Op: " ", Text: ""},
+				{Op: "-", Text: "func main() {}"},
+				noEOL,
+				{Op: "+", Text: "func main() {}"},
+			}}},
+		},
+		{
+			name: "newline removed",
+			a:    "a\n",
+			b:    "a",
+			want: []DiffHunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []DiffLine{
+				{Op: "-", Text: "a"},
+				{Op: "+", Text: "a"},
+				noEOL,
+			}}},
+		},
+		{
+			name: "unchanged last line without newline",
+			a:    "a\nb",
+			b:    "x\nb",
+			want: []DiffHunk{{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Lines: []DiffLine{
+				{Op: "-", Text: "a"},
+				{Op: "+", Text: "x"},
+				{Op: " ", Text: "b"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := diffText(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: diffText = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	diffs := diffFiles(
+		[]SourceFile{{Path: "main.go", Content: "package main"}},
+		[]SourceFile{{Path: "main.go", Content: "package main\n"}},
+	)
+	if len(diffs) != 1 || len(diffs[0].Hunks) != 1 {
+		t.Errorf("trailing newline change gave %+v, want one modified file with a hunk", diffs)
+	}
+}
@@ -11,16 +11,98 @@ type loginReq struct {
 	Password string `json:"password"`
 }
 
-type meResp struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
+type forgotPasswordReq struct {
 	Email string `json:"email"`
-	Role  string `json:"role"`
-	Photo string `json:"photo"`
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type verifyEmailReq struct {
+	Token string `json:"token"`
+}
+
+type twoFactorCodeReq struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type disableTwoFactorReq struct {
+	Password string `json:"password"`
+	twoFactorCodeReq
+}
+
+type meResp struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	PendingEmail  string `json:"pendingEmail,omitempty"`
+	TwoFactor     bool   `json:"twoFactorEnabled"`
+	Role          string `json:"role"`
+	Photo         string `json:"photo"`
 }
 
 type saveCodeReq struct {
-	UserID   string `json:"userId"`
-	LessonID string `json:"lessonId"`
-	Code     string `json:"code"`
+	UserID   string       `json:"userId"`
+	LessonID string       `json:"lessonId"`
+	Code     string       `json:"code"`
+	Files    []SourceFile `json:"files"`
+}
+
+type runReq struct {
+	Code      string       `json:"code"`
+	Files     []SourceFile `json:"files"`
+	Stdin     string       `json:"stdin"`
+	GoVersion string       `json:"goVersion"`
+	// TaskID, when set, runs with the task's pinned Go version unless
+	// GoVersion overrides it.
+	TaskID string `json:"taskId"`
+	// NoCache marks programs whose output depends on time or randomness,
+	// so an earlier result must not be replayed.
+	NoCache bool `json:"noCache"`
+	// Interactive keeps stdin open on a streaming run so more input can be
+	// sent through the stdin endpoint while the program is running.
+	Interactive bool `json:"interactive"`
+}
+
+type stdinReq struct {
+	Data string `json:"data"`
+	EOF  bool   `json:"eof"`
+}
+
+type submitReq struct {
+	Code  string       `json:"code"`
+	Files []SourceFile `json:"files"`
+}
+
+type analysisReq struct {
+	Code      string       `json:"code"`
+	Files     []SourceFile `json:"files"`
+	GoVersion string       `json:"goVersion"`
+	// TaskID, as for runReq, checks with the task's pinned Go version.
+	TaskID string `json:"taskId"`
+}
+
+type formatReq struct {
+	Code      string `json:"code"`
+	Imports   bool   `json:"imports"`
+	GoVersion string `json:"goVersion"`
+	// TaskID, as for runReq, formats with the task's pinned Go version.
+	TaskID string `json:"taskId"`
+}
+
+// taskReq is a task as an admin sends it. Slug and Order are pointers so
+// leaving them out can be told apart from setting them to "" or 0.
+type taskReq struct {
+	Task
+	Slug  *string `json:"slug"`
+	Order *int    `json:"order"`
 }
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// claimVerificationSend records that a confirmation mail is about to go to
+// the user, or reports false if one went out less than
+// verifyResendWait ago.
+func (s *Server) claimVerificationSend(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	now := time.Now().UTC()
+	res, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": userID, "$or": bson.A{
+			bson.M{"verificationSentAt": bson.M{"$exists": false}},
+			bson.M{"verificationSentAt": bson.M{"$lte": now.Add(-verifyResendWait)}},
+		}},
+		bson.M{"$set": bson.M{"verificationSentAt": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// sendVerification mails a confirmation link for email, which is either
+// the user's own address or the one they asked to change to.
+func (s *Server) sendVerification(ctx context.Context, u userDoc, email string) error {
+	token, err := s.issueToken(ctx, u.ID, tokenEmailVerify, email, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.appURL + "/verify-email?token=" + token
+	go s.deliver(Mail{
+		To:      email,
+		Subject: "Confirm your GoEdu email address",
+		Body: "Hi " + u.Name + ",\n\n" +
+			"Please confirm that " + email + " is your address by opening:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + emailVerifyTTL.String() + ". If you did not sign up for GoEdu, ignore this email.\n",
+	})
+	return nil
+}
+
+// deliver sends m outside the request that triggered it, so a slow mail
+// server does not hold up the response.
+func (s *Server) deliver(m Mail) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.mailer.Send(ctx, m); err != nil {
+		log.Printf("sending mail to %s failed: %v", m.To, err)
+	}
+}
+
+func writeResendLimited(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(verifyResendWait.Seconds())))
+	http.Error(w, "A confirmation email was sent recently, try again later", http.StatusTooManyRequests)
+}
+
+// handleVerifyEmail confirms the address a token was sent to. For a
+// pending change this is when the account's email actually changes.
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	t, err := s.consumeToken(ctx, req.Token, tokenEmailVerify)
+	if err == errInvalidToken {
+		http.Error(w, "Confirmation link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The filter pins the address the token was issued for, so a link for
+	// an address the user has since moved away from does nothing.
+	res, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": t.UserID, "email": t.Email},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	changed := false
+	if err == nil && res.MatchedCount == 0 {
+		changed = true
+		res, err = s.users.UpdateOne(ctx,
+			bson.M{"_id": t.UserID, "pendingEmail": t.Email},
+			bson.M{
+				"$set":   bson.M{"email": t.Email, "emailVerified": true},
+				"$unset": bson.M{"pendingEmail": ""},
+			},
+		)
+	}
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "Email already registered", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if res.MatchedCount == 0 {
+		http.Error(w, "Confirmation link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if changed {
+		s.logSecurityEvent(ctx, r, t.UserID, eventEmailChanged)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Email confirmed", "email": t.Email})
+}
+
+// handleResendVerification mails a new link for the pending address, or
+// for the account's address while it is unconfirmed.
+func (s *Server) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	email := u.PendingEmail
+	if email == "" {
+		if u.EmailVerified {
+			http.Error(w, "Email already confirmed", http.StatusConflict)
+			return
+		}
+		email = u.Email
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	ok, err := s.claimVerificationSend(ctx, u.ID)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeResendLimited(w)
+		return
+	}
+	if err := s.sendVerification(ctx, u, email); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "Confirmation sent to " + email})
+}
@@ -1,14 +1,15 @@
 package server
 
 import (
-	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,43 +19,41 @@ import (
 )
 
 func (s *Server) handleSaveCode(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
 	var req saveCodeReq
 	if err := decodeJSON(r, &req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if req.LessonID == "" {
+		http.Error(w, "lessonId required", http.StatusBadRequest)
+		return
+	}
+	if err := validateFiles(req.Files); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	uid, err := primitive.ObjectIDFromHex(req.UserID)
-
-	_, err = s.code_submissions.UpdateOne(
-		ctx,
-		bson.M{"userId": uid},
-		bson.M{
-			"$set": bson.M{
-				"code":      req.Code,
-				"updatedAt": time.Now().UTC(),
-				"lessonId":  req.LessonID,
-			},
-			"$setOnInsert": bson.M{
-				"createdAt": time.Now().UTC(),
-			},
-		},
-		options.Update().SetUpsert(true),
-	)
+	doc, err := s.saveVersion(ctx, codeSubmissionDoc{
+		UserID:   u.ID,
+		LessonID: req.LessonID,
+		Code:     req.Code,
+		Files:    req.Files,
+	})
 	if err != nil {
-		fmt.Println(req.UserID)
-		fmt.Printf("handleSaveCode UpdateOne error: %v\n", err)
+		log.Printf("handleSaveCode: %v", err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	fmt.Fprint(w, "Code saved")
+	writeJSON(w, http.StatusOK, doc.summary())
 }
 
+// handleGetSavedCode returns the latest saved version for a lesson.
 func (s *Server) handleGetSavedCode(w http.ResponseWriter, r *http.Request) {
 	u := r.Context().Value(ctxUserKey{}).(userDoc)
 	lessonID := r.URL.Query().Get("lessonId")
@@ -63,18 +62,12 @@ func (s *Server) handleGetSavedCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filter := bson.M{"userId": u.ID, "lessonId": lessonID}
-
-	var doc struct {
-		Code string `bson:"code" json:"code"`
-	}
-
-	err := s.code_submissions.FindOne(
-		r.Context(), filter,
-	).Decode(&doc)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
 
+	doc, err := s.latestVersion(ctx, u.ID, lessonID)
 	if err == mongo.ErrNoDocuments {
-		writeJSON(w, http.StatusOK, map[string]string{"code": ""})
+		writeJSON(w, http.StatusOK, map[string]any{"code": "", "files": []SourceFile{}})
 		return
 	}
 	if err != nil {
@@ -85,70 +78,194 @@ func (s *Server) handleGetSavedCode(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, doc)
 }
 
-func runHandler(w http.ResponseWriter, r *http.Request) {
-	var req saveCodeReq
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req runReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
+	if len(req.Stdin) > maxStdinBytes {
+		http.Error(w, "stdin too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 
-	dir, err := os.MkdirTemp("", "gorun-*")
+	goVersion, err := s.runGoVersion(r.Context(), req.GoVersion, req.TaskID)
 	if err != nil {
-		http.Error(w, "tempdir failed", 500)
+		writeGoVersionError(w, err)
 		return
 	}
-	defer os.RemoveAll(dir)
 
-	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(req.Code), 0644); err != nil {
-		http.Error(w, "write failed", 500)
+	files, err := buildWorkspace(req.Code, req.Files, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	args := []string{
-		"run", "--rm",
-		"-v", dir + ":/work",
-		"-w", "/work",
-		"golang:latest",
-		"go", "run", "main.go",
+	job := RunJob{
+		Files:     files,
+		Cmd:       []string{"go", "run", "."},
+		GoVersion: goVersion,
+	}
+	if req.Stdin != "" {
+		job.Stdin = strings.NewReader(req.Stdin)
 	}
 
-	cmd := exec.CommandContext(r.Context(), "docker", args...)
-	var outb, errb bytes.Buffer
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
+	cacheKey := runCacheKey(job, req.Stdin)
+	if !req.NoCache {
+		if resp, ok := s.runCache.get(cacheKey); ok {
+			resp.Cached = true
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
 
-	exitCode := 0
-	if err := cmd.Run(); err != nil {
-		// If docker/go run fails, we still return stderr.
-		exitCode = 1
+	ticket, err := s.queue.enqueue(u.ID)
+	if err != nil {
+		writeQueueError(w, err)
+		return
 	}
 
-	resp := RunResp{
-		Stdout:   outb.String(),
-		Stderr:   errb.String(),
-		ExitCode: exitCode,
+	resp, pos, err := s.runQueued(r.Context(), ticket, job)
+	if err != nil {
+		log.Printf("run failed: %v", err)
+		http.Error(w, "run failed", http.StatusInternalServerError)
+		return
 	}
+	resp.GoVersion = goVersion
+	if !req.NoCache {
+		s.runCache.put(cacheKey, resp)
+	}
+	resp.QueuePosition = pos
+
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+// taskCursor encodes the position after a task in display order, so a
+// page boundary stays put when tasks are added or reordered elsewhere.
+func taskCursor(t Task) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(t.Order) + "." + t.ID.Hex()))
 }
 
+func parseTaskCursor(c string) (int, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, primitive.NilObjectID, err
+	}
+	orderStr, idStr, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return 0, primitive.NilObjectID, errors.New("malformed cursor")
+	}
+	order, err := strconv.Atoi(orderStr)
+	if err != nil {
+		return 0, primitive.NilObjectID, err
+	}
+	id, err := primitive.ObjectIDFromHex(idStr)
+	return order, id, err
+}
+
+// handleListTasks pages through published tasks in display order.
+// Filters: tag (repeatable, all must match), difficulty, and solved=true
+// or false for the caller's own progress.
 func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	query := r.URL.Query()
+
+	filter := bson.M{"status": taskPublished}
+	if tags := query["tag"]; len(tags) > 0 {
+		filter["tags"] = bson.M{"$all": tags}
+	}
+	switch d := query.Get("difficulty"); d {
+	case "":
+	case difficultyEasy, difficultyMedium, difficultyHard:
+		filter["difficulty"] = d
+	default:
+		http.Error(w, "difficulty must be easy, medium or hard", http.StatusBadRequest)
+		return
+	}
+
+	limit := taskPageSize
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxTaskPageSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxTaskPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if c := query.Get("cursor"); c != "" {
+		order, id, err := parseTaskCursor(c)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter["$or"] = bson.A{
+			bson.M{"order": bson.M{"$gt": order}},
+			bson.M{"order": order, "_id": bson.M{"$gt": id}},
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	opts := options.Find().SetSort(bson.M{"createdAt": -1})
-	cur, err := s.tasks.Find(ctx, bson.M{}, opts)
+
+	passed, err := s.passedTasks(ctx, u.ID)
+	if err != nil {
+		http.Error(w, "Database error", 500)
+		return
+	}
+	switch query.Get("solved") {
+	case "":
+	case "true":
+		filter["_id"] = bson.M{"$in": passed}
+	case "false":
+		filter["_id"] = bson.M{"$nin": passed}
+	default:
+		http.Error(w, "solved must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	opts := options.Find().
+		SetSort(displayOrder).
+		SetProjection(studentTaskProjection).
+		SetLimit(int64(limit) + 1)
+	cur, err := s.tasks.Find(ctx, filter, opts)
 	if err != nil {
 		http.Error(w, "Database error", 500)
 		return
 	}
 	defer cur.Close(ctx)
 
-	var tasks []Task = []Task{}
+	var tasks []Task
 	if err := cur.All(ctx, &tasks); err != nil {
 		http.Error(w, "Error decoding tasks", 500)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, tasks)
+	solved := make(map[primitive.ObjectID]bool, len(passed))
+	for _, id := range passed {
+		solved[id] = true
+	}
+	page := TaskPage{Tasks: []TaskListItem{}}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		page.NextCursor = taskCursor(tasks[limit-1])
+	}
+	ids := make([]primitive.ObjectID, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	locked, err := s.lockedTasks(ctx, u, ids)
+	if err != nil {
+		http.Error(w, "Database error", 500)
+		return
+	}
+	for _, t := range tasks {
+		if locked[t.ID] {
+			t.Description, t.StarterFiles = "", []SourceFile{}
+		}
+		page.Tasks = append(page.Tasks, TaskListItem{Task: t, Solved: solved[t.ID], Locked: locked[t.ID]})
+	}
+
+	writeJSON(w, http.StatusOK, page)
 }
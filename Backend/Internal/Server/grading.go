@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// studentTaskProjection hides the parts of a task students may not see
+// in a listing.
+var studentTaskProjection = bson.M{"tests": 0, "hints": 0, "solution": 0, "editorial": 0}
+
+func (s *Server) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req submitReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findPublishedTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	if len(task.Tests) == 0 {
+		http.Error(w, "Task has no tests", http.StatusConflict)
+		return
+	}
+
+	goVersion, err := s.resolveGoVersion(r.Context(), "", &task)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+
+	files, err := buildWorkspace(req.Code, req.Files, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.gradeFiles(r.Context(), u.ID, task, goVersion, files)
+	if errors.Is(err, errQueueFull) || errors.Is(err, errUserBusy) {
+		writeQueueError(w, err)
+		return
+	}
+	if err != nil {
+		log.Printf("grading task %s failed: %v", task.ID.Hex(), err)
+		http.Error(w, "run failed", http.StatusInternalServerError)
+		return
+	}
+
+	progress, err := s.findProgress(r.Context(), u.ID, task.ID)
+	if err != nil {
+		log.Printf("loading progress on task %s failed: %v", task.ID.Hex(), err)
+	}
+	result.HintPenalty = hintPenalty(task.Hints, progress.HintsRevealed)
+	result.Assisted = progress.GaveUpAt != nil && progress.FirstPassedAt == nil
+	result.Score = taskScore(result)
+
+	if err := s.recordAttempt(r.Context(), u.ID, task.ID, submittedFiles(req.Code, req.Files), result); err != nil {
+		log.Printf("recording attempt on task %s failed: %v", task.ID.Hex(), err)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// gradeFiles runs a workspace against the task's hidden tests, replacing
+// any tests the workspace brings along, on userID's share of the queue.
+// The student's code is built on its own first, so compile errors come
+// from it alone; the graded run only reports verdicts and sanitized
+// failure messages, never the hidden tests' source.
+func (s *Server) gradeFiles(ctx context.Context, userID primitive.ObjectID, task Task, goVersion string, files map[string]string) (SubmitResp, error) {
+	files = withoutTests(files)
+
+	ticket, err := s.queue.enqueue(userID)
+	if err != nil {
+		return SubmitResp{}, err
+	}
+	defer ticket.release()
+	if err := ticket.wait(ctx, nil); err != nil {
+		return SubmitResp{}, err
+	}
+
+	build, err := s.runner.Run(ctx, RunJob{
+		Files:     files,
+		Cmd:       []string{"go", "build", "./..."},
+		GoVersion: goVersion,
+	})
+	if err != nil {
+		return SubmitResp{}, err
+	}
+	if build.ExitCode != 0 {
+		return SubmitResp{
+			Tests:       []TestResult{},
+			BuildOutput: strings.TrimSpace(build.Stdout + build.Stderr),
+			ExitCode:    build.ExitCode,
+			GoVersion:   goVersion,
+		}, nil
+	}
+
+	graded := make(map[string]string, len(files)+len(task.Tests))
+	for p, c := range files {
+		graded[p] = c
+	}
+	for _, f := range task.Tests {
+		graded[f.Path] = f.Content
+	}
+	resp, err := s.runner.Run(ctx, RunJob{
+		Files:     graded,
+		Cmd:       []string{"go", "test", "-json", "./..."},
+		GoVersion: goVersion,
+	})
+	if err != nil {
+		return SubmitResp{}, err
+	}
+
+	result := gradeTestOutput(resp, task.Tests)
+	result.GoVersion = goVersion
+	return result, nil
+}
+
+// testEvent is one line of `go test -json` (test2json) output.
+type testEvent struct {
+	Action  string  `json:"Action"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// gradeTestOutput turns a `go test -json` run against the hidden tests into
+// per-test results. Messages and build output go through sanitizeOutput,
+// since the run mixes the tests' logs with whatever the student's code
+// printed.
+func gradeTestOutput(run RunResp, hidden []SourceFile) SubmitResp {
+	resp := SubmitResp{Tests: []TestResult{}, ExitCode: run.ExitCode}
+
+	var build strings.Builder
+	outputs := map[string]*strings.Builder{}
+	index := map[string]int{}
+
+	sc := bufio.NewScanner(strings.NewReader(run.Stdout))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			build.WriteString(sc.Text())
+			build.WriteByte('\n')
+			continue
+		}
+
+		switch ev.Action {
+		case "build-output":
+			build.WriteString(ev.Output)
+			continue
+		case "output":
+			if ev.Test == "" {
+				continue
+			}
+			b := outputs[ev.Test]
+			if b == nil {
+				b = &strings.Builder{}
+				outputs[ev.Test] = b
+			}
+			b.WriteString(ev.Output)
+			continue
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		tr := TestResult{
+			Name:       ev.Test,
+			Status:     ev.Action,
+			DurationMs: int64(ev.Elapsed * 1000),
+		}
+		if ev.Action != "pass" && outputs[ev.Test] != nil {
+			tr.Message = sanitizeOutput(outputs[ev.Test].String(), hidden)
+		}
+		if i, ok := index[ev.Test]; ok {
+			resp.Tests[i] = tr
+		} else {
+			index[ev.Test] = len(resp.Tests)
+			resp.Tests = append(resp.Tests, tr)
+		}
+	}
+
+	build.WriteString(run.Stderr)
+	resp.BuildOutput = sanitizeOutput(build.String(), hidden)
+	if resp.BuildOutput == "" && len(resp.Tests) == 0 && run.ExitCode != 0 {
+		resp.BuildOutput = "The tests could not be run against your code."
+	}
+
+	failed := false
+	for _, t := range resp.Tests {
+		resp.Total++
+		switch t.Status {
+		case "pass":
+			resp.PassedCount++
+		case "fail":
+			failed = true
+		}
+	}
+	resp.Passed = run.ExitCode == 0 && resp.Total > 0 && !failed
+	return resp
+}
+
+const (
+	// maxMessageLen caps a sanitized message.
+	maxMessageLen = 2000
+	// minQuotedLen is the shortest hidden test line sanitizeOutput looks
+	// for; shorter ones such as braces and returns give nothing away.
+	minQuotedLen = 8
+)
+
+// locatedLine matches the file:line: prefix the testing package and the
+// compiler put on what they report.
+var locatedLine = regexp.MustCompile(`^(?:\./)?[\w./-]+\.go:\d+(?::\d+)?: `)
+
+// sanitizeOutput keeps the lines of out that the testing package or the
+// compiler reported against a source position. Everything else the
+// student's code printed is dropped, and so is any line quoting the hidden
+// tests' source.
+func sanitizeOutput(out string, hidden []SourceFile) string {
+	var secret []string
+	for _, f := range hidden {
+		for _, l := range strings.Split(f.Content, "\n") {
+			if l = strings.TrimSpace(l); len(l) >= minQuotedLen {
+				secret = append(secret, l)
+			}
+		}
+	}
+
+	var kept []string
+	n := 0
+lines:
+	for _, l := range strings.Split(out, "\n") {
+		l = strings.TrimSpace(l)
+		if !locatedLine.MatchString(l) {
+			continue
+		}
+		for _, src := range secret {
+			if strings.Contains(l, src) {
+				continue lines
+			}
+		}
+		if n += len(l) + 1; n > maxMessageLen {
+			break
+		}
+		kept = append(kept, l)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// validTestFile reports whether p can be used as a hidden test file.
+func validTestFile(p string) bool {
+	return strings.HasSuffix(p, "_test.go")
+}
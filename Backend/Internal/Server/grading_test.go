@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testJSON joins test2json events into the stdout of `go test -json`.
+func testJSON(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestGradeTestOutput(t *testing.T) {
+	tests := []struct {
+		name  string
+		run   RunResp
+		want  []TestResult
+		count [2]int // passed, total
+		pass  bool
+		build string
+	}{
+		{
+			name: "all pass",
+			run: RunResp{Stdout: testJSON(
+				`{"Action":"run","Test":"TestAdd"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}`,
+				`{"Action":"pass","Test":"TestAdd","Elapsed":0.012}`,
+				`{"Action":"run","Test":"TestSub"}`,
+				`{"Action":"pass","Test":"TestSub","Elapsed":0}`,
+				`{"Action":"pass","Elapsed":0.02}`,
+			)},
+			want: []TestResult{
+				{Name: "TestAdd", Status: "pass", DurationMs: 12},
+				{Name: "TestSub", Status: "pass"},
+			},
+			count: [2]int{2, 2},
+			pass:  true,
+		},
+		{
+			name: "failure keeps the test's output without framing lines",
+			run: RunResp{ExitCode: 1, Stdout: testJSON(
+				`{"Action":"run","Test":"TestAdd"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"    add_test.go:9: Add(1, 2) = 4, want 3\n"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"--- FAIL: TestAdd (0.00s)\n"}`,
+				`{"Action":"fail","Test":"TestAdd","Elapsed":0}`,
+				`{"Action":"skip","Test":"TestSlow","Elapsed":0}`,
+				`{"Action":"fail","Elapsed":0.01}`,
+			)},
+			want: []TestResult{
+				{Name: "TestAdd", Status: "fail", Message: "add_test.go:9: Add(1, 2) = 4, want 3"},
+				{Name: "TestSlow", Status: "skip"},
+			},
+			count: [2]int{0, 2},
+		},
+		{
+			name: "build failure",
+			run: RunResp{ExitCode: 1, Stderr: "# example\n./main.go:3:1: syntax error\n", Stdout: testJSON(
+				`{"ImportPath":"example","Action":"build-output","Output":"# example\n"}`,
+				`FAIL	example [build failed]`,
+			)},
+			want:  []TestResult{},
+			build: "./main.go:3:1: syntax error",
+		},
+		{
+			name: "student output is dropped from messages",
+			run: RunResp{ExitCode: 1, Stdout: testJSON(
+				`{"Action":"output","Test":"TestAdd","Output":"debug: adding\n"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"    add_test.go:9: Add(1, 2) = 4, want 3\n"}`,
+				`{"Action":"output","Test":"TestAdd","Output":"        more detail\n"}`,
+				`{"Action":"fail","Test":"TestAdd","Elapsed":0}`,
+			)},
+			want:  []TestResult{{Name: "TestAdd", Status: "fail", Message: "add_test.go:9: Add(1, 2) = 4, want 3"}},
+			count: [2]int{0, 1},
+		},
+		{
+			name:  "tests that do not build get a plain note",
+			run:   RunResp{ExitCode: 1, Stdout: "FAIL\texample [setup failed]\n"},
+			want:  []TestResult{},
+			build: "The tests could not be run against your code.",
+		},
+		{
+			name: "rerun test reports its last result",
+			run: RunResp{Stdout: testJSON(
+				`{"Action":"fail","Test":"TestFlaky","Elapsed":0}`,
+				`{"Action":"pass","Test":"TestFlaky","Elapsed":0}`,
+			)},
+			want:  []TestResult{{Name: "TestFlaky", Status: "pass"}},
+			count: [2]int{1, 1},
+			pass:  true,
+		},
+		{
+			name: "zero exit without tests is not a pass",
+			run:  RunResp{Stdout: "ok  \texample\t0.01s [no tests to run]\n"},
+			want: []TestResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gradeTestOutput(tt.run, nil)
+			if !reflect.DeepEqual(got.Tests, tt.want) {
+				t.Errorf("tests = %+v, want %+v", got.Tests, tt.want)
+			}
+			if got.PassedCount != tt.count[0] || got.Total != tt.count[1] {
+				t.Errorf("passed %d of %d, want %d of %d", got.PassedCount, got.Total, tt.count[0], tt.count[1])
+			}
+			if got.Passed != tt.pass {
+				t.Errorf("Passed = %v, want %v", got.Passed, tt.pass)
+			}
+			if tt.build != "" && got.BuildOutput != tt.build {
+				t.Errorf("build output = %q, want %q", got.BuildOutput, tt.build)
+			}
+			if got.ExitCode != tt.run.ExitCode {
+				t.Errorf("exit code = %d, want %d", got.ExitCode, tt.run.ExitCode)
+			}
+		})
+	}
+}
+
+func TestGradeFilesReplacesTests(t *testing.T) {
+	fake := &FakeRunner{Resp: RunResp{Stdout: testJSON(`{"Action":"pass","Test":"TestHidden","Elapsed":0}`)}}
+	s := &Server{runner: fake, queue: newRunQueue(1, 10, 0)}
+	task := Task{Tests: []SourceFile{{Path: "hidden_test.go", Content: "package main // hidden"}}}
+
+	res, err := s.gradeFiles(context.Background(), primitive.NewObjectID(), task, "1.22", map[string]string{
+		"main.go":      "package main",
+		"main_test.go": "package main // the student's own",
+	})
+	if err != nil {
+		t.Fatalf("gradeFiles: %v", err)
+	}
+	if !res.Passed || res.GoVersion != "1.22" {
+		t.Errorf("gradeFiles = %+v, want a pass on 1.22", res)
+	}
+
+	jobs := fake.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("runner called %d times, want a build and a test run", len(jobs))
+	}
+	if want := map[string]string{"main.go": "package main"}; !reflect.DeepEqual(jobs[0].Files, want) {
+		t.Errorf("built files = %v, want %v", jobs[0].Files, want)
+	}
+	if strings.Join(jobs[0].Cmd, " ") != "go build ./..." {
+		t.Errorf("first job = %v, want go build", jobs[0].Cmd)
+	}
+	want := map[string]string{"main.go": "package main", "hidden_test.go": "package main // hidden"}
+	if !reflect.DeepEqual(jobs[1].Files, want) {
+		t.Errorf("graded files = %v, want %v", jobs[1].Files, want)
+	}
+	if jobs[1].GoVersion != "1.22" || strings.Join(jobs[1].Cmd, " ") != "go test -json ./..." {
+		t.Errorf("job = %v on %q, want go test -json on 1.22", jobs[1].Cmd, jobs[1].GoVersion)
+	}
+}
+
+func TestGradeFilesStopsAtStudentBuildErrors(t *testing.T) {
+	fake := &FakeRunner{Resp: RunResp{ExitCode: 1, Stderr: "# play\n./main.go:3:1: syntax error\n"}}
+	s := &Server{runner: fake, queue: newRunQueue(1, 10, 0)}
+	task := Task{Tests: []SourceFile{{Path: "hidden_test.go", Content: "package main"}}}
+
+	res, err := s.gradeFiles(context.Background(), primitive.NewObjectID(), task, "1.22", map[string]string{"main.go": "package main\n\nfunc"})
+	if err != nil {
+		t.Fatalf("gradeFiles: %v", err)
+	}
+	if res.Passed || res.BuildOutput != "# play\n./main.go:3:1: syntax error" {
+		t.Errorf("gradeFiles = %+v, want the student's build error", res)
+	}
+	if n := len(fake.Jobs()); n != 1 {
+		t.Errorf("runner called %d times, want the tests skipped", n)
+	}
+}
+
+func TestGradeFilesHidesTestSource(t *testing.T) {
+	const hidden = `package main
+
+import "testing"
+
+func TestSecret(t *testing.T) {
+	if got := Answer(); got != 42 {
+		t.Errorf("Answer() = %d, want 42", got)
+	}
+}
+`
+	// The student's code dumps the hidden file from an init function, then
+	// again dressed up as a test log line.
+	leak, _ := json.Marshal(hidden)
+	fake := &FakeRunner{Func: func(job RunJob) (RunResp, error) {
+		if job.Cmd[1] == "build" {
+			return RunResp{}, nil
+		}
+		return RunResp{ExitCode: 1, Stderr: hidden, Stdout: testJSON(
+			`{"Action":"output","Test":"TestSecret","Output":`+string(leak)+`}`,
+			`{"Action":"output","Test":"TestSecret","Output":"    secret_test.go:6: \tif got := Answer(); got != 42 {\n"}`,
+			`{"Action":"output","Test":"TestSecret","Output":"    secret_test.go:7: Answer() = 41, want 42\n"}`,
+			`{"Action":"fail","Test":"TestSecret","Elapsed":0}`,
+		)}, nil
+	}}
+	s := &Server{runner: fake, queue: newRunQueue(1, 10, 0)}
+	task := Task{Tests: []SourceFile{{Path: "secret_test.go", Content: hidden}}}
+
+	res, err := s.gradeFiles(context.Background(), primitive.NewObjectID(), task, "1.22", map[string]string{"main.go": "package main"})
+	if err != nil {
+		t.Fatalf("gradeFiles: %v", err)
+	}
+	body, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(hidden, "\n") {
+		if line = strings.TrimSpace(line); len(line) >= minQuotedLen && strings.Contains(string(body), line) {
+			t.Errorf("response quotes the hidden tests: %q in %s", line, body)
+		}
+	}
+	want := []TestResult{{Name: "TestSecret", Status: "fail", Message: "secret_test.go:7: Answer() = 41, want 42"}}
+	if !reflect.DeepEqual(res.Tests, want) {
+		t.Errorf("tests = %+v, want %+v", res.Tests, want)
+	}
+}
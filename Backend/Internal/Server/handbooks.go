@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func validateHandbookPage(p *HandbookPage) map[string]string {
+	errs := map[string]string{}
+	p.Title = strings.TrimSpace(p.Title)
+	p.Slug = strings.TrimSpace(p.Slug)
+
+	if p.Title == "" {
+		errs["title"] = "required"
+	} else if len(p.Title) > 200 {
+		errs["title"] = "must be at most 200 characters"
+	}
+	if p.Slug == "" {
+		p.Slug = slugify(p.Title)
+	}
+	if !slugRegex.MatchString(p.Slug) {
+		errs["slug"] = "must be lowercase letters and digits joined by dashes"
+	}
+	if strings.TrimSpace(p.Markdown) == "" {
+		errs["markdown"] = "required"
+	}
+	switch p.Status {
+	case "":
+		p.Status = taskDraft
+	case taskDraft, taskPublished, taskArchived:
+	default:
+		errs["status"] = "must be draft, published or archived"
+	}
+	if p.Order < 0 {
+		errs["order"] = "must not be negative"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// publishedHandbookLinks lists published pages in reading order.
+func (s *Server) publishedHandbookLinks(ctx context.Context) ([]HandbookLink, error) {
+	cur, err := s.handbooks.Find(ctx, bson.M{"status": taskPublished},
+		options.Find().SetSort(displayOrder).SetProjection(bson.M{"slug": 1, "title": 1}))
+	if err != nil {
+		return nil, err
+	}
+	links := []HandbookLink{}
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *Server) handleListHandbooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	links, err := s.publishedHandbookLinks(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+// handleGetHandbook renders a published page with its table of contents
+// and its neighbours in reading order. Pages that belong only to lessons
+// the caller has not unlocked are refused.
+func (s *Server) handleGetHandbook(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	slug := chi.URLParam(r, "slug")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var page HandbookPage
+	err := s.handbooks.FindOne(ctx, bson.M{"slug": slug, "status": taskPublished}).Decode(&page)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	locked, err := s.handbookLocked(ctx, u, slug)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "Page is locked", http.StatusForbidden)
+		return
+	}
+
+	html, toc, err := renderMarkdown(page.Markdown)
+	if err != nil {
+		log.Printf("rendering handbook %s failed: %v", slug, err)
+		http.Error(w, "Render failed", http.StatusInternalServerError)
+		return
+	}
+
+	links, err := s.publishedHandbookLinks(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := HandbookPageResp{
+		Slug:      page.Slug,
+		Title:     page.Title,
+		HTML:      html,
+		TOC:       toc,
+		UpdatedAt: page.UpdatedAt,
+	}
+	for i, l := range links {
+		if l.Slug != page.Slug {
+			continue
+		}
+		if i > 0 {
+			resp.Prev = &links[i-1]
+		}
+		if i+1 < len(links) {
+			resp.Next = &links[i+1]
+		}
+		break
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleHandbookCSS serves the syntax highlighting stylesheet for rendered
+// code blocks.
+func (s *Server) handleHandbookCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := highlightCSS()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write([]byte(css))
+}
+
+func (s *Server) handleAdminListHandbooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.handbooks.Find(ctx, bson.M{}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	pages := []HandbookPage{}
+	if err := cur.All(ctx, &pages); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, pages)
+}
+
+func (s *Server) handleAdminCreateHandbook(w http.ResponseWriter, r *http.Request) {
+	var p HandbookPage
+	if err := decodeJSON(r, &p); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateHandbookPage(&p); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	p.ID = primitive.NewObjectID()
+	p.CreatedAt = time.Now().UTC()
+	p.UpdatedAt = p.CreatedAt
+	if _, err := s.handbooks.InsertOne(ctx, p); err != nil {
+		writeSlugWriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Server) handleAdminGetHandbook(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var p HandbookPage
+	if !findOne(ctx, w, s.handbooks, id, "Page", &p) {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleAdminUpdateHandbook(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var p HandbookPage
+	if err := decodeJSON(r, &p); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validateHandbookPage(&p); errs != nil {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var old HandbookPage
+	if !findOne(ctx, w, s.handbooks, id, "Page", &old) {
+		return
+	}
+	p.ID = old.ID
+	p.CreatedAt = old.CreatedAt
+	p.UpdatedAt = time.Now().UTC()
+	if _, err := s.handbooks.ReplaceOne(ctx, bson.M{"_id": id}, p); err != nil {
+		writeSlugWriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleAdminDeleteHandbook(w http.ResponseWriter, r *http.Request) {
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.handbooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminPreviewHandbook renders Markdown without saving it.
+func (s *Server) handleAdminPreviewHandbook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Markdown string `json:"markdown"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	html, toc, err := renderMarkdown(req.Markdown)
+	if err != nil {
+		http.Error(w, "Render failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, HandbookPageResp{HTML: html, TOC: toc})
+}
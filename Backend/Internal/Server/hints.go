@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// validateHints checks a task's hints, adding problems to errs.
+func validateHints(hints []Hint, errs map[string]string) {
+	if len(hints) > maxTaskHints {
+		errs["hints"] = fmt.Sprintf("must have at most %d hints", maxTaskHints)
+		return
+	}
+	for i, h := range hints {
+		if strings.TrimSpace(h.Text) == "" {
+			errs[fmt.Sprintf("hints[%d].text", i)] = "required"
+		}
+		if h.Penalty < 0 || h.Penalty > 100 {
+			errs[fmt.Sprintf("hints[%d].penalty", i)] = "must be between 0 and 100"
+		}
+	}
+}
+
+// hintPenalty is the score deducted for the first n hints of a task.
+func hintPenalty(hints []Hint, n int) int {
+	total := 0
+	for i := 0; i < n && i < len(hints); i++ {
+		total += hints[i].Penalty
+	}
+	return total
+}
+
+// findProgress returns the user's progress on a task, or a zero value
+// when there is none yet.
+func (s *Server) findProgress(ctx context.Context, userID, taskID primitive.ObjectID) (taskProgressDoc, error) {
+	var p taskProgressDoc
+	err := s.progress.FindOne(ctx, bson.M{"userId": userID, "taskId": taskID}).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return taskProgressDoc{UserID: userID, TaskID: taskID}, nil
+	}
+	return p, err
+}
+
+// findPublishedTask loads the task named by the {id} URL parameter as
+// students may see it, writing 400/403/404/500 when it cannot. Tasks of
+// lessons still locked for the caller are refused.
+func (s *Server) findPublishedTask(ctx context.Context, w http.ResponseWriter, r *http.Request) (Task, bool) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return Task{}, false
+	}
+
+	var task Task
+	err := s.tasks.FindOne(ctx, bson.M{"_id": id, "status": taskPublished}).Decode(&task)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return Task{}, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return Task{}, false
+	}
+
+	locked, err := s.lockedTasks(ctx, u, []primitive.ObjectID{id})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return Task{}, false
+	}
+	if locked[id] {
+		http.Error(w, "Task is locked", http.StatusForbidden)
+		return Task{}, false
+	}
+	return task, true
+}
+
+func hintsResp(task Task, revealed int) HintsResp {
+	resp := HintsResp{
+		Hints:   []RevealedHint{},
+		Total:   len(task.Hints),
+		Penalty: hintPenalty(task.Hints, revealed),
+	}
+	for i := 0; i < revealed && i < len(task.Hints); i++ {
+		resp.Hints = append(resp.Hints, RevealedHint{Index: i, Text: task.Hints[i].Text, Penalty: task.Hints[i].Penalty})
+	}
+	return resp
+}
+
+// handleListHints returns the hints the caller has revealed so far.
+func (s *Server) handleListHints(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findPublishedTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	p, err := s.findProgress(ctx, u.ID, task.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, hintsResp(task, p.HintsRevealed))
+}
+
+// handleNextHint reveals the caller's next hint. Each one lowers the score
+// of later submissions by its penalty.
+func (s *Server) handleNextHint(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findPublishedTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	if len(task.Hints) == 0 {
+		http.Error(w, "Task has no hints", http.StatusNotFound)
+		return
+	}
+
+	// The filter only matches while hints remain, so two concurrent
+	// requests cannot reveal past the end. When the document exists but
+	// everything is revealed, the upsert collides with the unique index.
+	now := time.Now().UTC()
+	var p taskProgressDoc
+	err := s.progress.FindOneAndUpdate(ctx,
+		bson.M{"userId": u.ID, "taskId": task.ID, "hintsRevealed": bson.M{"$not": bson.M{"$gte": len(task.Hints)}}},
+		bson.M{
+			"$inc":         bson.M{"hintsRevealed": 1},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&p)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "No more hints", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hintsResp(task, p.HintsRevealed))
+}
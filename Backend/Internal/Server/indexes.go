@@ -8,7 +8,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (s *Server) ensureIndexes(ctx context.Context) error {
+func (s *Store) ensureIndexes(ctx context.Context) error {
 	_, err := s.users.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "email", Value: 1}},
 		Options: options.Index().SetUnique(true),
@@ -29,5 +29,134 @@ func (s *Server) ensureIndexes(ctx context.Context) error {
 		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
 		Options: options.Index().SetExpireAfterSeconds(0),
 	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.code_submissions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "userId", Value: 1},
+			{Key: "lessonId", Value: 1},
+			{Key: "version", Value: -1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.modules.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "courseId", Value: 1}, {Key: "order", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.lessons.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "courseId", Value: 1}, {Key: "order", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.completions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "lessonId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Tasks created through the admin API need no slug; bundles always
+	// have one.
+	_, err = s.tasks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.handbooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only one text index is allowed per collection; /api/search relies on
+	// these.
+	_, err = s.tasks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "tags", Value: "text"},
+			{Key: "description", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("search").
+			SetWeights(bson.M{"title": 10, "tags": 5, "description": 1}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.handbooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "markdown", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("search").
+			SetWeights(bson.M{"title": 10, "markdown": 1}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.tokens.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.tokens.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.oauthStates.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.securityLog.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.progress.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "taskId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
 	return err
 }
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Mail is a plain-text message to a single recipient.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mail. SMTPMailer is used in production; LogMailer keeps
+// messages local for development and tests.
+type Mailer interface {
+	Send(ctx context.Context, m Mail) error
+}
+
+// newMailer picks the mailer from MAILER ("smtp" or "log"). The log mailer
+// would put reset and verification links in the server logs, so it is
+// only allowed, and the default, in dev mode.
+func newMailer(devMode bool) (Mailer, error) {
+	kind := os.Getenv("MAILER")
+	if kind == "" && devMode {
+		kind = "log"
+	}
+	switch kind {
+	case "":
+		return nil, fmt.Errorf("MAILER is required, e.g. MAILER=smtp")
+	case "smtp":
+		m := &SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			From:     os.Getenv("SMTP_FROM"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASS"),
+		}
+		if m.Addr == "" || m.From == "" {
+			return nil, fmt.Errorf("MAILER=smtp needs SMTP_ADDR and SMTP_FROM")
+		}
+		return m, nil
+	case "log":
+		if !devMode {
+			return nil, fmt.Errorf("MAILER=log is only allowed with DEV=1")
+		}
+		return &LogMailer{Dir: os.Getenv("MAIL_DIR")}, nil
+	default:
+		return nil, fmt.Errorf("MAILER: unknown mailer %q", kind)
+	}
+}
+
+// formatMail renders m as an RFC 5322 message.
+func formatMail(from string, m Mail) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTPMailer sends through an SMTP server, authenticating with PLAIN when
+// Username is set.
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	// net/smtp takes no context; run it aside so a caller can stop waiting.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, formatMail(m.From, msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer writes each message to a file in Dir, or to the log when Dir
+// is empty.
+type LogMailer struct {
+	Dir string
+
+	seq atomic.Int64
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Mail) error {
+	raw := formatMail("goedu@localhost", msg)
+	if m.Dir == "" {
+		log.Printf("mail:\n%s", raw)
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.Dir, name), raw, 0o600)
+}
@@ -0,0 +1,36 @@
+package server
+
+import "testing"
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		mailer  string
+		devMode bool
+		want    string // "" for an error
+	}{
+		{"", true, "log"},
+		{"log", true, "log"},
+		{"", false, ""},
+		{"log", false, ""},
+		{"smtp", false, "smtp"},
+		{"smtp", true, "smtp"},
+		{"carrier-pigeon", true, ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("MAILER", tt.mailer)
+		t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+		t.Setenv("SMTP_FROM", "noreply@example.com")
+
+		m, err := newMailer(tt.devMode)
+		got := ""
+		switch m.(type) {
+		case *LogMailer:
+			got = "log"
+		case *SMTPMailer:
+			got = "smtp"
+		}
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("MAILER=%q dev=%v: got %s, %v; want %q", tt.mailer, tt.devMode, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// highlightStyle is the chroma style served as the handbook stylesheet.
+const highlightStyle = "github"
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+		),
+	)
+
+	// Raw HTML in Markdown is already dropped by goldmark; the policy is a
+	// second line of defence against links and attributes it lets through.
+	markdownPolicy = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy()
+		p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span")
+		return p
+	}()
+
+	goFormatter = chromahtml.New(chromahtml.WithClasses(true))
+)
+
+// renderMarkdown turns handbook Markdown into sanitized HTML and a table of
+// contents built from its level 2 and 3 headings.
+func renderMarkdown(src string) (string, []TOCEntry, error) {
+	source := []byte(src)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	toc := []TOCEntry{}
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if h.Level == 2 || h.Level == 3 {
+			id, _ := h.AttributeString("id")
+			idb, _ := id.([]byte)
+			toc = append(toc, TOCEntry{Level: h.Level, ID: string(idb), Text: nodeText(h, source)})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, source, doc); err != nil {
+		return "", nil, err
+	}
+	return markdownPolicy.Sanitize(buf.String()), toc, nil
+}
+
+// nodeText returns the plain text inside n, without formatting.
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// markdownSection is the plain text under one heading of a page. The
+// text before the first heading has an empty ID.
+type markdownSection struct {
+	ID    string
+	Title string
+	Text  string
+}
+
+// markdownSections splits a page at its headings so search can point at
+// the part of a page that matched.
+func markdownSections(src string) []markdownSection {
+	source := []byte(src)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	sections := []markdownSection{{}}
+	var body strings.Builder
+	flush := func() {
+		sections[len(sections)-1].Text = strings.TrimSpace(body.String())
+		body.Reset()
+	}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if h, ok := n.(*ast.Heading); ok {
+			flush()
+			id, _ := h.AttributeString("id")
+			idb, _ := id.([]byte)
+			sections = append(sections, markdownSection{ID: string(idb), Title: nodeText(h, source)})
+			continue
+		}
+		body.WriteString(blockText(n, source))
+		body.WriteByte('\n')
+	}
+	flush()
+
+	if sections[0].Text == "" {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+// blockText returns the plain text of a block, keeping code verbatim.
+func blockText(n ast.Node, source []byte) string {
+	switch n.(type) {
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var b strings.Builder
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			b.Write(seg.Value(source))
+		}
+		return b.String()
+	}
+	return nodeText(n, source)
+}
+
+// codeBlockRenderer highlights fenced Go blocks with chroma classes and
+// leaves other languages as plain preformatted text.
+type codeBlockRenderer struct{}
+
+func (codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCodeBlock)
+}
+
+func renderCodeBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := n.(*ast.FencedCodeBlock)
+	lang := strings.ToLower(string(block.Language(source)))
+
+	var code strings.Builder
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		code.Write(seg.Value(source))
+	}
+
+	if lang == "go" || lang == "golang" {
+		if err := highlightGo(w, code.String()); err != nil {
+			return ast.WalkStop, err
+		}
+		return ast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.WriteString("<pre><code")
+	if lang != "" {
+		_, _ = w.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	_, _ = w.WriteString(">" + html.EscapeString(code.String()) + "</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+func highlightGo(w util.BufWriter, code string) error {
+	lexer := chroma.Coalesce(lexers.Get("go"))
+	it, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+	return goFormatter.Format(w, styles.Get(highlightStyle), it)
+}
+
+// highlightCSS is the stylesheet for the classes highlightGo emits.
+func highlightCSS() (string, error) {
+	var buf bytes.Buffer
+	if err := goFormatter.WriteCSS(&buf, styles.Get(highlightStyle)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -13,6 +13,8 @@ import (
 
 type ctxUserKey struct{}
 
+type ctxSessionKey struct{}
+
 func (s *Server) withSecurity(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -33,7 +35,9 @@ func (s *Server) withSecurity(next http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 
-		if r.URL.Path == "/api/login" || r.URL.Path == "/api/registration" {
+		switch r.URL.Path {
+		case "/api/login", "/api/login/2fa", "/api/2fa/disable", "/api/2fa/recovery-codes",
+			"/api/registration", "/api/password/forgot", "/api/password/reset", "/api/password/change":
 			if !s.allowRequest(r) {
 				http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
 				return
@@ -90,12 +94,13 @@ func clientIP(r *http.Request) string {
 
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		u, err := s.authenticate(r)
+		u, sess, err := s.authenticateSession(r)
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 		ctx := context.WithValue(r.Context(), ctxUserKey{}, u)
+		ctx = context.WithValue(ctx, ctxSessionKey{}, sess)
 		next(w, r.WithContext(ctx))
 	}
 }
@@ -113,14 +118,53 @@ func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if !u.TOTPEnabled {
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+			sec, err := s.securitySettings(ctx)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if sec.RequireAdminTwoFactor {
+				http.Error(w, "Admins must enable two-factor authentication", http.StatusForbidden)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// requireVerified admits only users who have confirmed their email.
+func (s *Server) requireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := r.Context().Value(ctxUserKey{}).(userDoc)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !u.EmailVerified {
+			http.Error(w, "Confirm your email address first", http.StatusForbidden)
+			return
+		}
+
 		next(w, r)
 	}
 }
 
 func (s *Server) authenticate(r *http.Request) (userDoc, error) {
+	u, _, err := s.authenticateSession(r)
+	return u, err
+}
+
+// authenticateSession returns the user and session behind the request's
+// cookie, and records the activity.
+func (s *Server) authenticateSession(r *http.Request) (userDoc, sessionDoc, error) {
 	raw, err := readSessionCookie(r)
 	if err != nil {
-		return userDoc{}, err
+		return userDoc{}, sessionDoc{}, err
 	}
 
 	th := sha256.Sum256([]byte(raw))
@@ -130,17 +174,24 @@ func (s *Server) authenticate(r *http.Request) (userDoc, error) {
 
 	var sess sessionDoc
 	if err := s.sessions.FindOne(ctx, bson.M{"tokenHash": th[:]}).Decode(&sess); err != nil {
-		return userDoc{}, errors.New("invalid session")
+		return userDoc{}, sessionDoc{}, errors.New("invalid session")
 	}
 
-	if time.Now().UTC().After(sess.ExpiresAt) {
-		return userDoc{}, errors.New("expired")
+	now := time.Now().UTC()
+	if now.After(sess.ExpiresAt) {
+		return userDoc{}, sessionDoc{}, errors.New("expired")
+	}
+	if sess.Pending {
+		return userDoc{}, sessionDoc{}, errors.New("second factor pending")
 	}
 
 	var u userDoc
 	if err := s.users.FindOne(ctx, bson.M{"_id": sess.UserID}).Decode(&u); err != nil {
-		return userDoc{}, errors.New("user not found")
+		return userDoc{}, sessionDoc{}, errors.New("user not found")
 	}
 
-	return u, nil
+	if now.Sub(sess.LastSeenAt) >= sessionTouchGap {
+		s.touchSession(ctx, &sess, r, now)
+	}
+	return u, sess, nil
 }
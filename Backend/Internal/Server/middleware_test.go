@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithSecurityThrottlesSecondFactorChecks(t *testing.T) {
+	for _, path := range []string{"/api/login/2fa", "/api/2fa/disable", "/api/2fa/recovery-codes"} {
+		t.Run(path, func(t *testing.T) {
+			s := &Server{rateByIP: make(map[string][]time.Time)}
+			h := s.withSecurity(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			for i := 0; i < loginRateMaxHits; i++ {
+				w := httptest.NewRecorder()
+				h(w, httptest.NewRequest(http.MethodPost, path, nil))
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("attempt %d: status %d", i+1, w.Code)
+				}
+			}
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest(http.MethodPost, path, nil))
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("attempt %d: status %d, want 429", loginRateMaxHits+1, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrate brings documents written by older versions up to date. Every
+// step is idempotent, so it runs on each start.
+func (s *Store) migrate(ctx context.Context) error {
+	// Tasks used to carry a single starterCode string.
+	_, err := s.tasks.UpdateMany(ctx,
+		bson.M{"starterCode": bson.M{"$exists": true}, "starterFiles": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"starterFiles": bson.A{
+				bson.M{"path": "main.go", "content": "$starterCode"},
+			}}}},
+			{{Key: "$unset", Value: "starterCode"}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := s.migrateLegacyTasks(ctx); err != nil {
+		return err
+	}
+
+	// Submissions used to be a single document per user, overwritten on
+	// every save; keep it as the first version of its lesson.
+	_, err = s.code_submissions.UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Accounts created before email verification are trusted as they are.
+	_, err = s.users.UpdateMany(ctx,
+		bson.M{"emailVerified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.migrateTaskTags(ctx)
+}
+
+// migrateLegacyTasks publishes tasks predating the lifecycle, which were
+// all visible to students, numbering them in the newest-first order the
+// task list used to show. Each task is updated on its own, so an
+// interrupted run resumes after the highest order already assigned.
+func (s *Store) migrateLegacyTasks(ctx context.Context) error {
+	cur, err := s.tasks.Find(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+			SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var legacy []Task
+	if err := cur.All(ctx, &legacy); err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	order, err := s.nextTaskOrder(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range legacy {
+		_, err := s.tasks.UpdateOne(ctx,
+			bson.M{"_id": t.ID, "status": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"status": taskPublished, "order": order}},
+		)
+		if err != nil {
+			return err
+		}
+		order++
+	}
+	return nil
+}
+
+// migrateTaskTags turns the single free-form tag tasks used to carry into
+// a tags list, adding each distinct tag to the taxonomy.
+func (s *Store) migrateTaskTags(ctx context.Context) error {
+	legacy := bson.M{"tag": bson.M{"$exists": true}}
+	values, err := s.tasks.Distinct(ctx, "tag", legacy)
+	if err != nil || len(values) == 0 {
+		return err
+	}
+
+	taxonomy, err := s.taskTags(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		name, _ := v.(string)
+		slug := slugify(name)
+		tags := bson.A{}
+		if slug != "" {
+			tags = append(tags, slug)
+			if !taxonomy.has(slug) {
+				taxonomy.Tags = append(taxonomy.Tags, TaskTag{Slug: slug, Name: strings.TrimSpace(name)})
+			}
+		}
+		_, err := s.tasks.UpdateMany(ctx,
+			bson.M{"tag": v},
+			bson.M{"$set": bson.M{"tags": tags}, "$unset": bson.M{"tag": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.settings.ReplaceOne(ctx, bson.M{"_id": tagSettingsID}, taxonomy, options.Replace().SetUpsert(true))
+	return err
+}
@@ -7,13 +7,43 @@ import (
 )
 
 type userDoc struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Name          string             `bson:"name"`
+	Email         string             `bson:"email"`
+	EmailVerified bool               `bson:"emailVerified"`
+	// PendingEmail is the address the user asked to change to; Email
+	// keeps the old one until the new one is confirmed.
+	PendingEmail       string     `bson:"pendingEmail,omitempty"`
+	VerificationSentAt *time.Time `bson:"verificationSentAt,omitempty"`
+	PassHash           []byte     `bson:"passHash"`
+	Role               string     `bson:"role"`
+	Photo              string     `bson:"photo,omitempty"`
+	CreatedAt          time.Time  `bson:"createdAt"`
+	// TOTPSecret is set from the start of enrollment; it only guards
+	// logins once TOTPEnabled is true.
+	TOTPSecret    string   `bson:"totpSecret,omitempty"`
+	TOTPEnabled   bool     `bson:"totpEnabled,omitempty"`
+	TOTPLastStep  int64    `bson:"totpLastStep,omitempty"`
+	RecoveryCodes [][]byte `bson:"recoveryCodes,omitempty"`
+	// Identities are the OpenID Connect accounts linked to this user.
+	Identities []oauthIdentity `bson:"identities,omitempty"`
+}
+
+type oauthIdentity struct {
+	Provider string    `bson:"provider"`
+	Subject  string    `bson:"subject"`
+	LinkedAt time.Time `bson:"linkedAt"`
+}
+
+// oauthStateDoc is an OpenID Connect login in flight, keyed by the hash
+// of its state parameter.
+type oauthStateDoc struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Name      string             `bson:"name"`
-	Email     string             `bson:"email"`
-	PassHash  []byte             `bson:"passHash"`
-	Role      string             `bson:"role"`
-	Photo     string             `bson:"photo,omitempty"`
-	CreatedAt time.Time          `bson:"createdAt"`
+	StateHash []byte             `bson:"stateHash"`
+	Provider  string             `bson:"provider"`
+	Nonce     string             `bson:"nonce"`
+	Verifier  string             `bson:"verifier"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
 }
 
 type sessionDoc struct {
@@ -22,6 +52,70 @@ type sessionDoc struct {
 	TokenHash []byte             `bson:"tokenHash"`
 	ExpiresAt time.Time          `bson:"expiresAt"`
 	CreatedAt time.Time          `bson:"createdAt"`
+	// ExpiresAt slides forward on activity but never past MaxExpiresAt.
+	MaxExpiresAt time.Time `bson:"maxExpiresAt"`
+	LastSeenAt   time.Time `bson:"lastSeenAt"`
+	UserAgent    string    `bson:"userAgent,omitempty"`
+	IP           string    `bson:"ip,omitempty"`
+	// Pending sessions have passed the password but still need the
+	// second factor; they authenticate nothing else.
+	Pending  bool `bson:"pending,omitempty"`
+	Attempts int  `bson:"attempts,omitempty"`
+}
+
+// userTokenDoc is a single-use token mailed to a user. Only its hash is
+// stored, like sessionDoc.TokenHash.
+type userTokenDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Purpose   string             `bson:"purpose"`
+	TokenHash []byte             `bson:"tokenHash"`
+	Email     string             `bson:"email,omitempty"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+type TwoFactorSetupResp struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// QR is the URI as a PNG data URL, ready for an <img> src.
+	QR string `json:"qr"`
+}
+
+type RecoveryCodesResp struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// securityEventDoc is one entry of a user's account security log.
+type securityEventDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Type      string             `bson:"type"`
+	IP        string             `bson:"ip"`
+	UserAgent string             `bson:"userAgent,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+type SecurityEventResp struct {
+	Type      string    `json:"type"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SessionResp struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+type OAuthProviderResp struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
 }
 
 type AdminUserResp struct {
@@ -32,26 +126,291 @@ type AdminUserResp struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// codeSubmissionDoc is one saved version of a user's code for a lesson.
+// Versions are numbered from 1 per (userId, lessonId) and never modified.
 type codeSubmissionDoc struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserID    primitive.ObjectID `bson:"userId"`
-	LessonID  primitive.ObjectID `bson:"lessonId"`
-	Code      string             `bson:"code"`
-	CreatedAt time.Time          `bson:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID       primitive.ObjectID `bson:"userId" json:"-"`
+	LessonID     string             `bson:"lessonId" json:"lessonId"`
+	Version      int                `bson:"version" json:"version"`
+	Code         string             `bson:"code" json:"code"`
+	Files        []SourceFile       `bson:"files,omitempty" json:"files"`
+	RestoredFrom int                `bson:"restoredFrom,omitempty" json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type submissionVersionResp struct {
+	Version      int       `json:"version"`
+	RestoredFrom int       `json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
 }
 
 type RunResp struct {
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
-	ExitCode int    `json:"exitCode"`
+	Stdout        string `json:"stdout"`
+	Stderr        string `json:"stderr"`
+	ExitCode      int    `json:"exitCode"`
+	GoVersion     string `json:"goVersion,omitempty"`
+	QueuePosition int    `json:"queuePosition,omitempty"`
+	Cached        bool   `json:"cached,omitempty"`
+	// TimedOut and Cancelled are set by runners when the run was killed
+	// before the program finished on its own.
+	TimedOut  bool `json:"timedOut,omitempty"`
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// interrupted reports whether the run was killed, so its result says
+// nothing about the program and must not be reused.
+func (r RunResp) interrupted() bool {
+	return r.TimedOut || r.Cancelled
 }
 
 type Task struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Slug         string             `bson:"slug,omitempty" json:"slug,omitempty"`
+	Title        string             `bson:"title" json:"title"`
+	Tags         []string           `bson:"tags" json:"tags"`
+	Difficulty   string             `bson:"difficulty,omitempty" json:"difficulty,omitempty"`
+	Description  string             `bson:"description" json:"description"`
+	StarterFiles []SourceFile       `bson:"starterFiles" json:"starterFiles"`
+	Tests        []SourceFile       `bson:"tests,omitempty" json:"tests,omitempty"`
+	Hints        []Hint             `bson:"hints,omitempty" json:"hints,omitempty"`
+	Solution     []SourceFile       `bson:"solution,omitempty" json:"solution,omitempty"`
+	Editorial    string             `bson:"editorial,omitempty" json:"editorial,omitempty"`
+	RevealAfter  int                `bson:"revealAfter,omitempty" json:"revealAfter,omitempty"`
+	GoVersion    string             `bson:"goVersion,omitempty" json:"goVersion,omitempty"`
+	Status       string             `bson:"status" json:"status"`
+	Order        int                `bson:"order" json:"order"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// TaskListItem is a task as listed to a student. Locked tasks belong only
+// to lessons the student has not unlocked and come without their content.
+type TaskListItem struct {
+	Task
+	Solved bool `json:"solved"`
+	Locked bool `json:"locked,omitempty"`
+}
+
+// TaskPage is one page of /api/tasks. NextCursor is empty on the last page.
+type TaskPage struct {
+	Tasks      []TaskListItem `json:"tasks"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// Hint is revealed to students one at a time, in order. Penalty is the
+// number of points (out of 100) it costs.
+type Hint struct {
+	Text    string `bson:"text" json:"text" yaml:"text"`
+	Penalty int    `bson:"penalty" json:"penalty" yaml:"penalty,omitempty"`
+}
+
+type RevealedHint struct {
+	Index   int    `json:"index"`
+	Text    string `json:"text"`
+	Penalty int    `json:"penalty"`
+}
+
+type SolutionResp struct {
+	Solution  []SourceFile `json:"solution"`
+	Editorial string       `json:"editorial"`
+}
+
+// HintsResp lists the hints a student has revealed and what they cost.
+type HintsResp struct {
+	Hints   []RevealedHint `json:"hints"`
+	Total   int            `json:"total"`
+	Penalty int            `json:"penalty"`
+}
+
+type SourceFile struct {
+	Path    string `bson:"path" json:"path"`
+	Content string `bson:"content" json:"content"`
+}
+
+type TestResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+	Message    string `json:"message,omitempty"`
+}
+
+type SubmitResp struct {
+	Passed      bool         `json:"passed"`
+	Total       int          `json:"total"`
+	PassedCount int          `json:"passedCount"`
+	Tests       []TestResult `json:"tests"`
+	BuildOutput string       `json:"buildOutput,omitempty"`
+	ExitCode    int          `json:"exitCode"`
+	GoVersion   string       `json:"goVersion"`
+	Score       int          `json:"score"`
+	HintPenalty int          `json:"hintPenalty,omitempty"`
+	// Assisted is set when the student had already given up and seen the
+	// solution; such passes earn no score.
+	Assisted bool `json:"assisted,omitempty"`
+}
+
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Code     string `json:"code,omitempty"`
+}
+
+type DiagnosticsResp struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type FormatResp struct {
+	Code        string       `json:"code"`
+	Changed     bool         `json:"changed"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type Course struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title       string             `bson:"title" json:"title"`
-	Tag         string             `bson:"tag" json:"tag"`
 	Description string             `bson:"description" json:"description"`
-	StarterCode string             `bson:"starterCode" json:"starterCode"`
+	Status      string             `bson:"status" json:"status"`
+	Order       int                `bson:"order" json:"order"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+type Module struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CourseID  primitive.ObjectID `bson:"courseId" json:"courseId"`
+	Title     string             `bson:"title" json:"title"`
+	Order     int                `bson:"order" json:"order"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Lesson belongs to a module and points at tasks and handbook pages.
+// Prerequisites are lessons of the same course that must be completed
+// before this one unlocks.
+type Lesson struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	CourseID      primitive.ObjectID   `bson:"courseId" json:"courseId"`
+	ModuleID      primitive.ObjectID   `bson:"moduleId" json:"moduleId"`
+	Title         string               `bson:"title" json:"title"`
+	Order         int                  `bson:"order" json:"order"`
+	TaskIDs       []primitive.ObjectID `bson:"taskIds" json:"taskIds"`
+	HandbookPages []string             `bson:"handbookPages" json:"handbookPages"`
+	Prerequisites []primitive.ObjectID `bson:"prerequisites" json:"prerequisites"`
+	CreatedAt     time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+type lessonCompletionDoc struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	LessonID    primitive.ObjectID `bson:"lessonId"`
+	CompletedAt time.Time          `bson:"completedAt"`
+}
+
+type LessonNode struct {
+	Lesson
+	Locked    bool `json:"locked"`
+	Completed bool `json:"completed"`
+}
+
+type ModuleNode struct {
+	Module
+	Lessons []LessonNode `json:"lessons"`
+}
+
+type CourseTree struct {
+	Course
+	Modules []ModuleNode `json:"modules"`
+}
+
+// taskProgressDoc is a user's record for one task, updated by every
+// graded submission.
+type taskProgressDoc struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	UserID           primitive.ObjectID `bson:"userId"`
+	TaskID           primitive.ObjectID `bson:"taskId"`
+	Attempts         int                `bson:"attempts"`
+	FailedAttempts   int                `bson:"failedAttempts"`
+	BestScore        int                `bson:"bestScore"`
+	HintsRevealed    int                `bson:"hintsRevealed"`
+	FirstPassedAt    *time.Time         `bson:"firstPassedAt,omitempty"`
+	FirstPassedFiles []SourceFile       `bson:"firstPassedFiles,omitempty"`
+	GaveUpAt         *time.Time         `bson:"gaveUpAt,omitempty"`
+	LastAttemptAt    *time.Time         `bson:"lastAttemptAt,omitempty"`
+	CreatedAt        time.Time          `bson:"createdAt"`
+}
+
+type TaskProgress struct {
+	TaskID        string     `json:"taskId"`
+	Title         string     `json:"title"`
+	Attempts      int        `json:"attempts"`
+	BestScore     int        `json:"bestScore"`
+	HintsRevealed int        `json:"hintsRevealed"`
+	Passed        bool       `json:"passed"`
+	FirstPassedAt *time.Time `json:"firstPassedAt,omitempty"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+}
+
+type CourseProgress struct {
+	CourseID         string `json:"courseId"`
+	Title            string `json:"title"`
+	CompletedLessons int    `json:"completedLessons"`
+	TotalLessons     int    `json:"totalLessons"`
+	Percent          int    `json:"percent"`
+}
+
+type ProgressResp struct {
+	Solved  int              `json:"solved"`
+	Tasks   []TaskProgress   `json:"tasks"`
+	Courses []CourseProgress `json:"courses"`
+}
+
+// HandbookPage is a handbook chapter written in Markdown. Pages are read
+// in order and addressed by slug.
+type HandbookPage struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Slug      string             `bson:"slug" json:"slug"`
+	Title     string             `bson:"title" json:"title"`
+	Markdown  string             `bson:"markdown" json:"markdown"`
+	Status    string             `bson:"status" json:"status"`
+	Order     int                `bson:"order" json:"order"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+type HandbookLink struct {
+	Slug  string `bson:"slug" json:"slug"`
+	Title string `bson:"title" json:"title"`
+}
+
+type TOCEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+type HandbookPageResp struct {
+	Slug      string        `json:"slug,omitempty"`
+	Title     string        `json:"title,omitempty"`
+	HTML      string        `json:"html"`
+	TOC       []TOCEntry    `json:"toc"`
+	Prev      *HandbookLink `json:"prev"`
+	Next      *HandbookLink `json:"next"`
+	UpdatedAt time.Time     `json:"updatedAt,omitempty"`
+}
+
+// SearchResult is one hit of /api/search. Snippet is HTML with the matched
+// words wrapped in <mark>.
+type SearchResult struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id"`
+	Slug    string   `json:"slug,omitempty"`
+	Title   string   `json:"title"`
+	Section string   `json:"section,omitempty"`
+	Anchor  string   `json:"anchor,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Snippet string   `json:"snippet"`
+	Score   float64  `json:"score"`
 }
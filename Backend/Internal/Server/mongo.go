@@ -4,54 +4,61 @@ import (
 	"context"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func New() (*Server, error) {
 	_ = godotenv.Load()
 
 	port := getenv("PORT", "8080")
-	_ = port
 	staticDir := getenv("STATIC_DIR", ".")
 	devMode := os.Getenv("DEV") == "1"
 
-	mongoURI := os.Getenv("MONGODB_URI")
-	dbName := getenv("MONGODB_DB", "goedu")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	runner, err := newRunner()
 	if err != nil {
 		return nil, err
 	}
-	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+	queue, err := newRunQueueFromEnv()
+	if err != nil {
 		return nil, err
 	}
-
-	db := client.Database(dbName)
-
-	s := &Server{
-		client:           client,
-		db:               db,
-		users:            db.Collection("users"),
-		sessions:         db.Collection("sessions"),
-		code_submissions: db.Collection("code_submissions"),
-		tasks:            db.Collection("tasks"),
-		staticDir:        staticDir,
-		devMode:          devMode,
-		rateByIP:         make(map[string][]time.Time),
-		emailRegex:       regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`),
+	runCache, err := newRunCacheFromEnv()
+	if err != nil {
+		return nil, err
 	}
-
-	if err := s.ensureIndexes(context.Background()); err != nil {
+	mailer, err := newMailer(devMode)
+	if err != nil {
 		return nil, err
 	}
+	appURL := strings.TrimSuffix(getenv("APP_URL", "http://localhost:"+port), "/")
+	oauthProviders, err := oauthProvidersFromEnv(appURL)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Store:          store,
+		staticDir:      staticDir,
+		devMode:        devMode,
+		runner:         runner,
+		queue:          queue,
+		runCache:       runCache,
+		goimports:      os.Getenv("GOIMPORTS_CMD"),
+		staticcheck:    os.Getenv("STATICCHECK_CMD"),
+		mailer:         mailer,
+		appURL:         appURL,
+		oauthProviders: oauthProviders,
+		rateByIP:       make(map[string][]time.Time),
+		liveRuns:       make(map[string]*liveRun),
+		emailRegex:     regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`),
+	}
 
 	s.setupRouter()
 	return s, nil
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
+)
+
+const oauthCookieName = "goedu_oauth"
+
+var errEmailNotVerified = errors.New("the provider has not verified this email address")
+
+// oauthProvider is one "Sign in with ..." OpenID Connect provider. The
+// issuer is discovered on first use, so the server starts even while a
+// provider is unreachable.
+type oauthProvider struct {
+	Name         string
+	Label        string
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	redirectURL  string
+
+	mu       sync.Mutex
+	oidc     *oidc.Provider
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oauthProvidersFromEnv reads OIDC_PROVIDERS, a comma-separated list of
+// names, and for each name N the variables OIDC_N_ISSUER, OIDC_N_CLIENT_ID,
+// OIDC_N_CLIENT_SECRET, and optionally OIDC_N_SCOPES and OIDC_N_LABEL.
+func oauthProvidersFromEnv(appURL string) (map[string]*oauthProvider, error) {
+	providers := map[string]*oauthProvider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slugRegex.MatchString(name) {
+			return nil, fmt.Errorf("OIDC_PROVIDERS: invalid provider name %q", name)
+		}
+		env := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		p := &oauthProvider{
+			Name:         name,
+			Label:        getenv(env+"LABEL", strings.ToUpper(name[:1])+name[1:]),
+			issuer:       os.Getenv(env + "ISSUER"),
+			clientID:     os.Getenv(env + "CLIENT_ID"),
+			clientSecret: os.Getenv(env + "CLIENT_SECRET"),
+			scopes:       strings.Fields(strings.ReplaceAll(getenv(env+"SCOPES", "openid email profile"), ",", " ")),
+			redirectURL:  appURL + "/api/oauth/" + name + "/callback",
+		}
+		if p.issuer == "" || p.clientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", env, env)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+// discover fetches the issuer's configuration once it is first needed.
+func (p *oauthProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oidc != nil {
+		return nil
+	}
+
+	op, err := oidc.NewProvider(ctx, p.issuer)
+	if err != nil {
+		return err
+	}
+	p.oidc = op
+	p.config = oauth2.Config{
+		ClientID:     p.clientID,
+		ClientSecret: p.clientSecret,
+		Endpoint:     op.Endpoint(),
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.scopes,
+	}
+	p.verifier = op.Verifier(&oidc.Config{ClientID: p.clientID})
+	return nil
+}
+
+// oauthClaims are the parts of an ID token or userinfo response a login
+// needs.
+type oauthClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// exchange redeems an authorization code and returns the verified claims
+// of the signed-in user.
+func (p *oauthProvider) exchange(ctx context.Context, code string, st oauthStateDoc) (oauthClaims, error) {
+	tok, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return oauthClaims{}, err
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return oauthClaims{}, errors.New("no id_token in token response")
+	}
+	idt, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return oauthClaims{}, err
+	}
+	if idt.Nonce != st.Nonce {
+		return oauthClaims{}, errors.New("id_token nonce mismatch")
+	}
+
+	var c oauthClaims
+	if err := idt.Claims(&c); err != nil {
+		return oauthClaims{}, err
+	}
+	// Some providers keep the email out of the ID token.
+	if c.Email == "" {
+		info, err := p.oidc.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+		if err != nil {
+			return oauthClaims{}, err
+		}
+		var ic oauthClaims
+		if err := info.Claims(&ic); err != nil {
+			return oauthClaims{}, err
+		}
+		if ic.Subject != idt.Subject {
+			return oauthClaims{}, errors.New("userinfo subject mismatch")
+		}
+		c.Email, c.EmailVerified = info.Email, info.EmailVerified
+		if c.Name == "" {
+			c.Name = ic.Name
+		}
+	}
+	c.Subject = idt.Subject
+	c.Email = strings.ToLower(strings.TrimSpace(c.Email))
+	return c, nil
+}
+
+// oauthUser finds the user a provider identity belongs to. An identity
+// seen before maps straight to its user; otherwise it is linked to the
+// account with the same email, or a new account is created.
+func (s *Server) oauthUser(ctx context.Context, provider string, c oauthClaims) (userDoc, error) {
+	var u userDoc
+	err := s.users.FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{
+		"provider": provider, "subject": c.Subject,
+	}}}).Decode(&u)
+	if err != mongo.ErrNoDocuments {
+		return u, err
+	}
+
+	if !c.EmailVerified || !s.emailRegex.MatchString(c.Email) {
+		return userDoc{}, errEmailNotVerified
+	}
+	identity := oauthIdentity{Provider: provider, Subject: c.Subject, LinkedAt: time.Now().UTC()}
+
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = s.users.FindOneAndUpdate(ctx,
+		bson.M{"email": c.Email, "emailVerified": true},
+		bson.M{
+			"$push":  bson.M{"identities": identity},
+			"$unset": bson.M{"pendingEmail": ""},
+		},
+		after,
+	).Decode(&u)
+	if err != mongo.ErrNoDocuments {
+		return u, err
+	}
+
+	// Anyone could have registered an unconfirmed account under this
+	// address ahead of its owner. The owner takes it over, but without
+	// the password, second factor or sessions the registrant set up.
+	err = s.users.FindOneAndUpdate(ctx,
+		bson.M{"email": c.Email, "emailVerified": bson.M{"$ne": true}},
+		bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  bson.M{"emailVerified": true},
+			"$unset": bson.M{
+				"passHash":      "",
+				"pendingEmail":  "",
+				"totpSecret":    "",
+				"totpEnabled":   "",
+				"totpLastStep":  "",
+				"recoveryCodes": "",
+			},
+		},
+		after,
+	).Decode(&u)
+	if err == nil {
+		if _, err := s.sessions.DeleteMany(ctx, bson.M{"userId": u.ID}); err != nil {
+			return userDoc{}, err
+		}
+		if _, err := s.tokens.DeleteMany(ctx, bson.M{"userId": u.ID}); err != nil {
+			return userDoc{}, err
+		}
+		return u, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return userDoc{}, err
+	}
+
+	name := strings.TrimSpace(c.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(c.Email, "@")
+	}
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	u = userDoc{
+		Name:          name,
+		Email:         c.Email,
+		EmailVerified: true,
+		Role:          "user",
+		CreatedAt:     time.Now().UTC(),
+		Identities:    []oauthIdentity{identity},
+	}
+	res, err := s.users.InsertOne(ctx, u)
+	if err != nil {
+		return userDoc{}, err
+	}
+	u.ID = res.InsertedID.(primitive.ObjectID)
+	return u, nil
+}
+
+func (s *Server) oauthProvider(w http.ResponseWriter, r *http.Request) (*oauthProvider, bool) {
+	p, ok := s.oauthProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		http.Error(w, "Unknown provider", http.StatusNotFound)
+	}
+	return p, ok
+}
+
+// oauthFail sends the browser back to the login page with a message.
+func oauthFail(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(msg), http.StatusFound)
+}
+
+func (s *Server) handleListOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	list := []OAuthProviderResp{}
+	for _, p := range s.oauthProviders {
+		list = append(list, OAuthProviderResp{Name: p.Name, Label: p.Label})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleOAuthLogin sends the browser to the provider. The state, nonce and
+// PKCE verifier are kept server-side, and the state is also put in a
+// cookie so the callback only completes in the browser that started it.
+func (s *Server) handleOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := p.discover(ctx); err != nil {
+		log.Printf("oidc discovery for %s failed: %v", p.Name, err)
+		oauthFail(w, r, p.Label+" sign-in is unavailable right now")
+		return
+	}
+
+	state, stateHash, err := newToken()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, _, err := newToken()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	st := oauthStateDoc{
+		StateHash: stateHash,
+		Provider:  p.Name,
+		Nonce:     nonce,
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: time.Now().UTC().Add(oauthStateTTL),
+	}
+	if _, err := s.oauthStates.InsertOne(ctx, st); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookieName,
+		Value:    state,
+		Path:     "/api/oauth/",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   !s.devMode,
+	})
+	http.Redirect(w, r, p.config.AuthCodeURL(state,
+		oidc.Nonce(st.Nonce),
+		oauth2.S256ChallengeOption(st.Verifier),
+	), http.StatusFound)
+}
+
+// handleOAuthCallback finishes a provider login and starts a session, or a
+// pending one if the account uses two-factor authentication.
+func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: oauthCookieName, Path: "/api/oauth/", MaxAge: -1})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		oauthFail(w, r, p.Label+" sign-in was cancelled")
+		return
+	}
+	c, err := r.Cookie(oauthCookieName)
+	if err != nil || c.Value == "" || c.Value != q.Get("state") {
+		oauthFail(w, r, "Sign-in expired, please try again")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sh := sha256.Sum256([]byte(c.Value))
+	var st oauthStateDoc
+	err = s.oauthStates.FindOneAndDelete(ctx, bson.M{
+		"stateHash": sh[:],
+		"provider":  p.Name,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(&st)
+	if err != nil {
+		oauthFail(w, r, "Sign-in expired, please try again")
+		return
+	}
+
+	if err := p.discover(ctx); err != nil {
+		log.Printf("oidc discovery for %s failed: %v", p.Name, err)
+		oauthFail(w, r, p.Label+" sign-in is unavailable right now")
+		return
+	}
+	claims, err := p.exchange(ctx, q.Get("code"), st)
+	if err != nil {
+		log.Printf("oidc login with %s failed: %v", p.Name, err)
+		oauthFail(w, r, p.Label+" sign-in failed")
+		return
+	}
+
+	u, err := s.oauthUser(ctx, p.Name, claims)
+	if errors.Is(err, errEmailNotVerified) {
+		oauthFail(w, r, p.Label+" has not verified your email address")
+		return
+	}
+	if err != nil {
+		log.Printf("linking %s identity failed: %v", p.Name, err)
+		oauthFail(w, r, "Sign-in failed")
+		return
+	}
+
+	if u.TOTPEnabled {
+		if err := s.createPendingSession(w, r, u.ID); err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/login?step=2fa", http.StatusFound)
+		return
+	}
+	if err := s.createSession(w, r, u.ID); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+
+	"goedu/Internal/mockoidc"
+)
+
+const testRedirectURL = "http://app.test/api/oauth/mock/callback"
+
+// startMockOIDC runs a mock provider signing in email and returns a
+// discovered oauthProvider pointed at it.
+func startMockOIDC(t *testing.T, email string, verified bool) *oauthProvider {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	issuer := "http://" + srv.Listener.Addr().String()
+	mock, err := mockoidc.New(mockoidc.Config{
+		Issuer:       issuer,
+		ClientID:     "goedu",
+		ClientSecret: "secret",
+		Email:        email,
+		Name:         "Ada Lovelace",
+		Verified:     verified,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.Handler = mock
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	p := &oauthProvider{
+		Name:         "mock",
+		Label:        "Mock",
+		issuer:       issuer,
+		clientID:     "goedu",
+		clientSecret: "secret",
+		scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		redirectURL:  testRedirectURL,
+	}
+	if err := p.discover(context.Background()); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	return p
+}
+
+// authorize follows the provider's authorization endpoint the way
+// handleOAuthLogin sends the browser there, and returns the query of the
+// redirect back to the callback.
+func authorize(t *testing.T, p *oauthProvider, state string, st oauthStateDoc) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(p.config.AuthCodeURL(state,
+		oidc.Nonce(st.Nonce),
+		oauth2.S256ChallengeOption(st.Verifier),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, want 302", resp.StatusCode)
+	}
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := back.Scheme + "://" + back.Host + back.Path; got != testRedirectURL {
+		t.Fatalf("redirected to %s, want the callback", got)
+	}
+	return back.Query()
+}
+
+func TestOAuthExchange(t *testing.T) {
+	p := startMockOIDC(t, "Ada@Example.com", true)
+	st := oauthStateDoc{Nonce: "nonce-1", Verifier: oauth2.GenerateVerifier()}
+
+	q := authorize(t, p, "state-1", st)
+	if q.Get("state") != "state-1" || q.Get("code") == "" {
+		t.Fatalf("callback query = %v, want the state and a code", q)
+	}
+
+	c, err := p.exchange(context.Background(), q.Get("code"), st)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	want := oauthClaims{
+		Subject:       mockoidc.Subject("Ada@Example.com"),
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "Ada Lovelace",
+	}
+	if c != want {
+		t.Errorf("claims = %+v, want %+v", c, want)
+	}
+
+	if _, err := p.exchange(context.Background(), q.Get("code"), st); err == nil {
+		t.Error("an authorization code was redeemed twice")
+	}
+}
+
+func TestOAuthExchangeRejects(t *testing.T) {
+	p := startMockOIDC(t, "ada@example.com", true)
+
+	tests := []struct {
+		name   string
+		change func(st *oauthStateDoc)
+	}{
+		{"wrong PKCE verifier", func(st *oauthStateDoc) { st.Verifier = oauth2.GenerateVerifier() }},
+		{"nonce mismatch", func(st *oauthStateDoc) { st.Nonce = "someone else's" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := oauthStateDoc{Nonce: "nonce-1", Verifier: oauth2.GenerateVerifier()}
+			code := authorize(t, p, "state-1", st).Get("code")
+			tt.change(&st)
+			if _, err := p.exchange(context.Background(), code, st); err == nil {
+				t.Error("exchange succeeded")
+			}
+		})
+	}
+
+	// An ID token issued and signed by another provider is refused.
+	other := startMockOIDC(t, "ada@example.com", true)
+	st := oauthStateDoc{Nonce: "nonce-1", Verifier: oauth2.GenerateVerifier()}
+	code := authorize(t, other, "state-1", st).Get("code")
+	forged := &oauthProvider{oidc: p.oidc, verifier: p.verifier, config: other.config}
+	if _, err := forged.exchange(context.Background(), code, st); err == nil {
+		t.Error("accepted an ID token from a different issuer")
+	}
+}
+
+func TestOAuthExchangeUnverifiedEmail(t *testing.T) {
+	p := startMockOIDC(t, "ada@example.com", false)
+	st := oauthStateDoc{Nonce: "n", Verifier: oauth2.GenerateVerifier()}
+
+	c, err := p.exchange(context.Background(), authorize(t, p, "s", st).Get("code"), st)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if c.EmailVerified {
+		t.Error("unverified email reported as verified")
+	}
+}
+
+// callbackRequest builds a request to the mock provider's callback as the
+// router would hand it to handleOAuthCallback.
+func callbackRequest(query, cookie string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/oauth/mock/callback?"+query, nil)
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: oauthCookieName, Value: cookie})
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("provider", "mock")
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestOAuthCallbackRejectsBeforeExchange(t *testing.T) {
+	s := &Server{oauthProviders: map[string]*oauthProvider{
+		"mock": {Name: "mock", Label: "Mock"},
+	}}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+		msg    string
+	}{
+		{"provider error", "error=access_denied&state=abc", "abc", "Mock sign-in was cancelled"},
+		{"no state cookie", "code=c&state=abc", "", "Sign-in expired, please try again"},
+		{"state from another browser", "code=c&state=abc", "xyz", "Sign-in expired, please try again"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handleOAuthCallback(w, callbackRequest(tt.query, tt.cookie))
+			if w.Code != http.StatusFound {
+				t.Fatalf("status %d, want 302", w.Code)
+			}
+			loc, _ := url.Parse(w.Header().Get("Location"))
+			if loc.Path != "/login" || loc.Query().Get("error") != tt.msg {
+				t.Errorf("redirected to %s, want /login with %q", loc, tt.msg)
+			}
+			if !strings.Contains(w.Header().Get("Set-Cookie"), oauthCookieName+"=;") {
+				t.Errorf("state cookie not cleared: %q", w.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r := callbackRequest("code=c&state=abc", "abc")
+	chi.RouteContext(r.Context()).URLParams = chi.RouteParams{}
+	chi.RouteContext(r.Context()).URLParams.Add("provider", "unknown")
+	s.handleOAuthCallback(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: status %d, want 404", w.Code)
+	}
+}
+
+func TestOAuthProvidersFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "google, my-idp")
+	t.Setenv("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "id")
+	t.Setenv("OIDC_MY_IDP_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "id2")
+	t.Setenv("OIDC_MY_IDP_SCOPES", "openid,email")
+	t.Setenv("OIDC_MY_IDP_LABEL", "Example IdP")
+
+	ps, err := oauthProvidersFromEnv("https://app.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, idp := ps["google"], ps["my-idp"]
+	if g == nil || idp == nil || len(ps) != 2 {
+		t.Fatalf("providers = %v", ps)
+	}
+	if g.Label != "Google" || g.redirectURL != "https://app.example.com/api/oauth/google/callback" {
+		t.Errorf("google = %q, %q", g.Label, g.redirectURL)
+	}
+	if idp.Label != "Example IdP" || strings.Join(idp.scopes, " ") != "openid email" {
+		t.Errorf("my-idp = %q, %v", idp.Label, idp.scopes)
+	}
+
+	t.Setenv("OIDC_PROVIDERS", "broken")
+	if _, err := oauthProvidersFromEnv("https://app.example.com"); err == nil {
+		t.Error("provider without an issuer accepted")
+	}
+}
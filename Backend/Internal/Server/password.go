@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordProblem describes why a new password is not acceptable, or
+// returns "" when it is.
+func passwordProblem(p string) string {
+	switch {
+	case len(p) < minPasswordLen:
+		return fmt.Sprintf("Password must be at least %d characters", minPasswordLen)
+	case len(p) > maxPasswordBytes:
+		return fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes)
+	}
+	return ""
+}
+
+// handleForgotPassword mails a reset link when the address belongs to an
+// account. The answer is the same either way, and the mail goes out after
+// responding, so neither content nor timing tells which emails exist.
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if s.emailRegex.MatchString(email) {
+		go s.sendPasswordReset(email)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"status": "If that address has an account, a reset link is on its way",
+	})
+}
+
+func (s *Server) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var u userDoc
+	if err := s.users.FindOne(ctx, bson.M{"email": email}).Decode(&u); err != nil {
+		return
+	}
+
+	token, err := s.issueToken(ctx, u.ID, tokenPasswordReset, "", passwordResetTTL)
+	if err != nil {
+		log.Printf("issuing reset token failed: %v", err)
+		return
+	}
+
+	link := s.appURL + "/reset-password?token=" + token
+	err = s.mailer.Send(ctx, Mail{
+		To:      u.Email,
+		Subject: "Reset your GoEdu password",
+		Body: "Hi " + u.Name + ",\n\n" +
+			"Someone asked to reset the password of your GoEdu account. To choose a new one, open:\n\n" +
+			link + "\n\n" +
+			"The link works once and expires in " + passwordResetTTL.String() + ". If you did not ask for this, ignore this email.\n",
+	})
+	if err != nil {
+		log.Printf("sending reset mail failed: %v", err)
+	}
+}
+
+// handleResetPassword sets a new password from a reset token and signs the
+// account out everywhere.
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if msg := passwordProblem(req.Password); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	t, err := s.consumeToken(ctx, req.Token, tokenPasswordReset)
+	if err == errInvalidToken {
+		http.Error(w, "Reset link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcryptCost)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := s.users.UpdateByID(ctx, t.UserID, bson.M{"$set": bson.M{"passHash": passHash}})
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if res.MatchedCount == 0 {
+		http.Error(w, "Reset link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+
+	s.logSecurityEvent(ctx, r, t.UserID, eventPasswordReset)
+
+	if _, err := s.sessions.DeleteMany(ctx, bson.M{"userId": t.UserID}); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Password updated, please log in"})
+}
+
+// handleChangePassword replaces the password of the signed-in user. Every
+// other session is signed out and the current one gets a new token, so a
+// leaked cookie does not outlive the old password.
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	sess := r.Context().Value(ctxSessionKey{}).(sessionDoc)
+
+	var req changePasswordReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if bcrypt.CompareHashAndPassword(u.PassHash, []byte(req.CurrentPassword)) != nil {
+		http.Error(w, "Wrong password", http.StatusForbidden)
+		return
+	}
+	if msg := passwordProblem(req.NewPassword); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		http.Error(w, "New password must differ from the current one", http.StatusBadRequest)
+		return
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcryptCost)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.users.UpdateByID(ctx, u.ID, bson.M{"$set": bson.M{"passHash": passHash}}); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	s.logSecurityEvent(ctx, r, u.ID, eventPasswordChanged)
+
+	// A reset link sent before the change must not undo it.
+	if _, err := s.tokens.DeleteMany(ctx, bson.M{"userId": u.ID, "purpose": tokenPasswordReset}); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.revokeOtherSessions(ctx, u.ID, sess.ID); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.sessions.DeleteOne(ctx, bson.M{"_id": sess.ID}); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.createSession(w, r, u.ID); err != nil {
+		clearCookie(w, s.devMode)
+		http.Error(w, "Password changed, please log in again", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Password changed"})
+}
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type updateProfileReq struct {
@@ -46,24 +45,56 @@ func (s *Server) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	// A new address only becomes the account's email once it is
+	// confirmed; until then it waits in pendingEmail. Asking for the
+	// current address again cancels a pending change.
 	update := bson.M{
-		"$set": bson.M{
-			"name":  req.Name,
-			"email": req.Email,
-		},
+		"$set":   bson.M{"name": req.Name},
+		"$unset": bson.M{"pendingEmail": ""},
 	}
-
-	_, err = s.users.UpdateByID(ctx, u.ID, update)
-	if err != nil {
-		if mongo.IsDuplicateKeyError(err) {
+	changing := req.Email != u.Email
+	if changing {
+		taken, err := s.users.CountDocuments(ctx, bson.M{"email": req.Email})
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if taken > 0 {
 			http.Error(w, `{"error":"Email already exists"}`, http.StatusConflict)
 			return
 		}
+
+		if req.Email != u.PendingEmail {
+			ok, err := s.claimVerificationSend(ctx, u.ID)
+			if err != nil {
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				writeResendLimited(w)
+				return
+			}
+		}
+		update = bson.M{"$set": bson.M{"name": req.Name, "pendingEmail": req.Email}}
+	}
+
+	_, err = s.users.UpdateByID(ctx, u.ID, update)
+	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	resp := map[string]string{"status": "ok"}
+	if changing {
+		if req.Email != u.PendingEmail {
+			if err := s.sendVerification(ctx, u, req.Email); err != nil {
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		resp["pendingEmail"] = req.Email
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleUploadPhoto(w http.ResponseWriter, r *http.Request) {
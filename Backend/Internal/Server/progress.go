@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// taskScore is the percentage of tests a graded run passed, less the
+// penalty for revealed hints.
+func taskScore(res SubmitResp) int {
+	if res.Total == 0 || res.Assisted {
+		return 0
+	}
+	return max(0, res.PassedCount*100/res.Total-res.HintPenalty)
+}
+
+// recordAttempt stores a graded submission in the user's progress. The
+// first passing attempt is kept with its files; later ones only count.
+// Passes after giving up only count too, so the task stays unsolved.
+func (s *Server) recordAttempt(ctx context.Context, userID, taskID primitive.ObjectID, files []SourceFile, res SubmitResp) error {
+	now := time.Now().UTC()
+
+	inc := bson.M{"attempts": 1}
+	if !res.Passed {
+		inc["failedAttempts"] = 1
+	}
+	_, err := s.progress.UpdateOne(ctx,
+		bson.M{"userId": userID, "taskId": taskID},
+		bson.M{
+			"$inc":         inc,
+			"$max":         bson.M{"bestScore": res.Score},
+			"$set":         bson.M{"lastAttemptAt": now},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil || !res.Passed || res.Assisted {
+		return err
+	}
+
+	_, err = s.progress.UpdateOne(ctx,
+		bson.M{
+			"userId":        userID,
+			"taskId":        taskID,
+			"firstPassedAt": bson.M{"$exists": false},
+			"gaveUpAt":      bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{
+			"firstPassedAt":    now,
+			"firstPassedFiles": files,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	return s.syncLessonCompletion(ctx, userID, taskID)
+}
+
+// syncLessonCompletion completes every lesson containing taskID whose
+// tasks have now all been passed by the user.
+func (s *Server) syncLessonCompletion(ctx context.Context, userID, taskID primitive.ObjectID) error {
+	cur, err := s.lessons.Find(ctx, bson.M{"taskIds": taskID})
+	if err != nil {
+		return err
+	}
+	var lessons []Lesson
+	if err := cur.All(ctx, &lessons); err != nil {
+		return err
+	}
+	return s.completeEarnedLessons(ctx, userID, lessons)
+}
+
+// completeEarnedLessons completes those of lessons whose tasks have all
+// been passed and whose prerequisites are done. A lesson still locked
+// stays incomplete until its last prerequisite is completed, which brings
+// it back here as a dependent.
+func (s *Server) completeEarnedLessons(ctx context.Context, userID primitive.ObjectID, lessons []Lesson) error {
+	for len(lessons) > 0 {
+		l := lessons[0]
+		lessons = lessons[1:]
+
+		n, err := s.progress.CountDocuments(ctx, bson.M{
+			"userId":        userID,
+			"taskId":        bson.M{"$in": l.TaskIDs},
+			"firstPassedAt": bson.M{"$exists": true},
+		})
+		if err != nil {
+			return err
+		}
+		if int(n) < len(l.TaskIDs) {
+			continue
+		}
+		done, err := s.completedLessons(ctx, userID, l.Prerequisites)
+		if err != nil {
+			return err
+		}
+		if !lessonEarned(l, int(n), done) {
+			continue
+		}
+
+		res, err := s.completions.UpdateOne(ctx,
+			bson.M{"userId": userID, "lessonId": l.ID},
+			bson.M{"$setOnInsert": bson.M{"completedAt": time.Now().UTC()}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		if res.UpsertedCount == 0 {
+			continue
+		}
+		next, err := s.dependentTaskLessons(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		lessons = append(lessons, next...)
+	}
+	return nil
+}
+
+// dependentTaskLessons returns the lessons with tasks that have lessonID
+// as a prerequisite.
+func (s *Server) dependentTaskLessons(ctx context.Context, lessonID primitive.ObjectID) ([]Lesson, error) {
+	cur, err := s.lessons.Find(ctx, bson.M{
+		"prerequisites": lessonID,
+		"taskIds.0":     bson.M{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var lessons []Lesson
+	if err := cur.All(ctx, &lessons); err != nil {
+		return nil, err
+	}
+	return lessons, nil
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.progress.Find(ctx, bson.M{"userId": u.ID},
+		options.Find().SetProjection(bson.M{"firstPassedFiles": 0}))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var docs []taskProgressDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	taskIDs := make([]primitive.ObjectID, 0, len(docs))
+	for _, d := range docs {
+		taskIDs = append(taskIDs, d.TaskID)
+	}
+	titles, err := s.taskTitles(ctx, taskIDs)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ProgressResp{Tasks: []TaskProgress{}, Courses: []CourseProgress{}}
+	for _, d := range docs {
+		resp.Tasks = append(resp.Tasks, TaskProgress{
+			TaskID:        d.TaskID.Hex(),
+			Title:         titles[d.TaskID],
+			Attempts:      d.Attempts,
+			BestScore:     d.BestScore,
+			HintsRevealed: d.HintsRevealed,
+			Passed:        d.FirstPassedAt != nil,
+			FirstPassedAt: d.FirstPassedAt,
+			LastAttemptAt: d.LastAttemptAt,
+		})
+		if d.FirstPassedAt != nil {
+			resp.Solved++
+		}
+	}
+
+	resp.Courses, err = s.courseProgress(ctx, u.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) taskTitles(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	titles := map[primitive.ObjectID]string{}
+	if len(ids) == 0 {
+		return titles, nil
+	}
+	cur, err := s.tasks.Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"title": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	if err := cur.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		titles[t.ID] = t.Title
+	}
+	return titles, nil
+}
+
+// courseProgress reports completed lessons per published course.
+func (s *Server) courseProgress(ctx context.Context, userID primitive.ObjectID) ([]CourseProgress, error) {
+	cur, err := s.courses.Find(ctx, bson.M{"status": taskPublished}, options.Find().SetSort(displayOrder))
+	if err != nil {
+		return nil, err
+	}
+	var courses []Course
+	if err := cur.All(ctx, &courses); err != nil {
+		return nil, err
+	}
+
+	out := make([]CourseProgress, 0, len(courses))
+	for _, c := range courses {
+		cur, err := s.lessons.Find(ctx, bson.M{"courseId": c.ID}, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var lessons []Lesson
+		if err := cur.All(ctx, &lessons); err != nil {
+			return nil, err
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(lessons))
+		for _, l := range lessons {
+			ids = append(ids, l.ID)
+		}
+		done, err := s.completedLessons(ctx, userID, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		cp := CourseProgress{
+			CourseID:         c.ID.Hex(),
+			Title:            c.Title,
+			TotalLessons:     len(ids),
+			CompletedLessons: len(done),
+		}
+		if cp.TotalLessons > 0 {
+			cp.Percent = cp.CompletedLessons * 100 / cp.TotalLessons
+		}
+		out = append(out, cp)
+	}
+	return out, nil
+}
+
+// passedTasks returns the IDs of the tasks the user has passed at least
+// once.
+func (s *Server) passedTasks(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cur, err := s.progress.Find(ctx,
+		bson.M{"userId": userID, "firstPassedAt": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"taskId": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var docs []taskProgressDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, d := range docs {
+		ids[i] = d.TaskID
+	}
+	return ids, nil
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errQueueFull = errors.New("run queue is full")
+	errUserBusy  = errors.New("too many runs in progress")
+)
+
+// runQueue bounds how many sandbox runs execute at once. Runs beyond the
+// worker count wait in FIFO order; admission fails outright when the
+// waiting list is full or the user already has perUser runs queued or
+// running.
+type runQueue struct {
+	workers  int
+	capacity int
+	perUser  int
+
+	mu      sync.Mutex
+	running int
+	waiting []*runTicket
+	byUser  map[primitive.ObjectID]int
+}
+
+type runTicket struct {
+	q     *runQueue
+	user  primitive.ObjectID
+	ready chan struct{}
+	moved chan struct{}
+	done  bool
+}
+
+func newRunQueue(workers, capacity, perUser int) *runQueue {
+	return &runQueue{
+		workers:  workers,
+		capacity: capacity,
+		perUser:  perUser,
+		byUser:   make(map[primitive.ObjectID]int),
+	}
+}
+
+// newRunQueueFromEnv sizes the queue from RUN_WORKERS, RUN_QUEUE_SIZE and
+// RUN_PER_USER.
+func newRunQueueFromEnv() (*runQueue, error) {
+	workers, err := getenvInt("RUN_WORKERS", runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := getenvInt("RUN_QUEUE_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+	perUser, err := getenvInt("RUN_PER_USER", 2)
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		return nil, errors.New("RUN_WORKERS must be at least 1")
+	}
+	return newRunQueue(workers, capacity, perUser), nil
+}
+
+// enqueue admits a run for user without blocking. The ticket must be
+// released once the run is over, whether or not wait succeeded.
+func (q *runQueue) enqueue(user primitive.ObjectID) (*runTicket, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.perUser > 0 && q.byUser[user] >= q.perUser {
+		return nil, errUserBusy
+	}
+
+	t := &runTicket{
+		q:     q,
+		user:  user,
+		ready: make(chan struct{}),
+		moved: make(chan struct{}, 1),
+	}
+	if q.running < q.workers {
+		q.running++
+		close(t.ready)
+	} else {
+		if len(q.waiting) >= q.capacity {
+			return nil, errQueueFull
+		}
+		q.waiting = append(q.waiting, t)
+	}
+	q.byUser[user]++
+	return t, nil
+}
+
+// position is the 1-based place of t in the waiting list, or 0 once it
+// holds a worker.
+func (t *runTicket) position() int {
+	t.q.mu.Lock()
+	defer t.q.mu.Unlock()
+	for i, w := range t.q.waiting {
+		if w == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// wait blocks until t holds a worker. onWait, if not nil, is told the
+// queue position on entry and every time it changes.
+func (t *runTicket) wait(ctx context.Context, onWait func(pos int)) error {
+	last := -1
+	for {
+		select {
+		case <-t.ready:
+			return nil
+		default:
+		}
+
+		if pos := t.position(); pos != last && pos > 0 {
+			last = pos
+			if onWait != nil {
+				onWait(pos)
+			}
+		}
+
+		select {
+		case <-t.ready:
+			return nil
+		case <-t.moved:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release gives back the worker, or the waiting slot if t never got one.
+// It is safe to call more than once.
+func (t *runTicket) release() {
+	q := t.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+
+	if q.byUser[t.user]--; q.byUser[t.user] <= 0 {
+		delete(q.byUser, t.user)
+	}
+
+	for i, w := range q.waiting {
+		if w == t {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.notifyMoved()
+			return
+		}
+	}
+
+	if len(q.waiting) == 0 {
+		q.running--
+		return
+	}
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	close(next.ready)
+	q.notifyMoved()
+}
+
+func (q *runQueue) notifyMoved() {
+	for _, w := range q.waiting {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// writeQueueError answers a failed enqueue with 503 or 429 and a
+// Retry-After hint.
+func writeQueueError(w http.ResponseWriter, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(queueRetryAfter.Seconds())))
+	if errors.Is(err, errUserBusy) {
+		http.Error(w, "You already have runs in progress, try again shortly", http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, "Server is busy, try again later", http.StatusServiceUnavailable)
+}
+
+// runQueued waits for a worker and runs job on it. pos is the queue
+// position the run started at, 0 when it did not wait.
+func (s *Server) runQueued(ctx context.Context, t *runTicket, job RunJob) (resp RunResp, pos int, err error) {
+	defer t.release()
+
+	err = t.wait(ctx, func(p int) {
+		if pos == 0 {
+			pos = p
+		}
+	})
+	if err != nil {
+		return RunResp{}, pos, err
+	}
+
+	resp, err = s.runner.Run(ctx, job)
+	return resp, pos, err
+}
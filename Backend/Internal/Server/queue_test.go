@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func mustEnqueue(t *testing.T, q *runQueue, user primitive.ObjectID) *runTicket {
+	t.Helper()
+	tk, err := q.enqueue(user)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	return tk
+}
+
+func isReady(tk *runTicket) bool {
+	select {
+	case <-tk.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunQueueAdmission(t *testing.T) {
+	q := newRunQueue(1, 2, 2)
+	alice, bob, carol := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	running := mustEnqueue(t, q, alice)
+	if !isReady(running) || running.position() != 0 {
+		t.Fatalf("first ticket should hold the worker")
+	}
+	first := mustEnqueue(t, q, alice)
+	second := mustEnqueue(t, q, bob)
+	if first.position() != 1 || second.position() != 2 {
+		t.Fatalf("positions = %d, %d, want 1, 2", first.position(), second.position())
+	}
+
+	if _, err := q.enqueue(alice); !errors.Is(err, errUserBusy) {
+		t.Errorf("third run for one user: err = %v, want errUserBusy", err)
+	}
+	if _, err := q.enqueue(carol); !errors.Is(err, errQueueFull) {
+		t.Errorf("enqueue on a full queue: err = %v, want errQueueFull", err)
+	}
+
+	// Leaving the waiting list frees its slot and moves later tickets up.
+	first.release()
+	if second.position() != 1 {
+		t.Errorf("position after the ticket ahead left = %d, want 1", second.position())
+	}
+	third := mustEnqueue(t, q, carol)
+
+	// Finishing a run hands the worker to the head of the line.
+	running.release()
+	if !isReady(second) || isReady(third) {
+		t.Fatalf("worker should pass to the first waiting ticket only")
+	}
+	if third.position() != 1 {
+		t.Errorf("position = %d, want 1", third.position())
+	}
+
+	running.release() // releasing twice is a no-op
+	if isReady(third) {
+		t.Fatalf("a second release handed out another worker")
+	}
+
+	second.release()
+	third.release()
+	if q.running != 0 || len(q.waiting) != 0 || len(q.byUser) != 0 {
+		t.Errorf("queue not empty after every release: running=%d waiting=%d users=%d",
+			q.running, len(q.waiting), len(q.byUser))
+	}
+}
+
+func TestRunTicketWait(t *testing.T) {
+	q := newRunQueue(1, 10, 0)
+	user := primitive.NewObjectID()
+
+	running := mustEnqueue(t, q, user)
+	ahead := mustEnqueue(t, q, user)
+	tk := mustEnqueue(t, q, user)
+
+	positions := make(chan int, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- tk.wait(context.Background(), func(pos int) { positions <- pos })
+	}()
+
+	if pos := <-positions; pos != 2 {
+		t.Fatalf("first reported position = %d, want 2", pos)
+	}
+	ahead.release()
+	if pos := <-positions; pos != 1 {
+		t.Fatalf("reported position after moving up = %d, want 1", pos)
+	}
+	running.release()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return once the ticket got a worker")
+	}
+	tk.release()
+}
+
+func TestRunTicketWaitCanceled(t *testing.T) {
+	q := newRunQueue(1, 10, 0)
+	user := primitive.NewObjectID()
+
+	running := mustEnqueue(t, q, user)
+	tk := mustEnqueue(t, q, user)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tk.wait(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait = %v, want context.Canceled", err)
+	}
+	tk.release()
+
+	running.release()
+	if q.running != 0 || len(q.waiting) != 0 {
+		t.Errorf("canceled ticket kept its place: running=%d waiting=%d", q.running, len(q.waiting))
+	}
+}
+
+func TestRunQueued(t *testing.T) {
+	fake := &FakeRunner{Resp: RunResp{Stdout: "hello\n"}}
+	s := &Server{runner: fake, queue: newRunQueue(1, 10, 0)}
+	user := primitive.NewObjectID()
+	job := RunJob{Files: map[string]string{"main.go": "package main"}, Cmd: []string{"go", "run", "."}}
+
+	resp, pos, err := s.runQueued(context.Background(), mustEnqueue(t, s.queue, user), job)
+	if err != nil {
+		t.Fatalf("runQueued: %v", err)
+	}
+	if resp.Stdout != "hello\n" || pos != 0 {
+		t.Errorf("runQueued = %+v at position %d, want the fake's output without waiting", resp, pos)
+	}
+	if jobs := fake.Jobs(); len(jobs) != 1 || jobs[0].Files["main.go"] != "package main" {
+		t.Errorf("runner saw jobs %+v, want the one queued job", jobs)
+	}
+
+	// A run that gives up while waiting never reaches the runner and
+	// leaves the line.
+	blocker := mustEnqueue(t, s.queue, user)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, pos, err = s.runQueued(ctx, mustEnqueue(t, s.queue, user), job)
+	if !errors.Is(err, context.Canceled) || pos != 1 {
+		t.Errorf("canceled runQueued = %v at position %d, want context.Canceled at 1", err, pos)
+	}
+	if n := len(fake.Jobs()); n != 1 {
+		t.Errorf("runner called %d times, want 1", n)
+	}
+	blocker.release()
+	if s.queue.running != 0 || len(s.queue.waiting) != 0 {
+		t.Errorf("runQueued did not release its ticket")
+	}
+}
@@ -15,20 +15,90 @@ func (s *Server) setupRouter() {
 	r.Route("/api", func(api chi.Router) {
 		api.Post("/registration", s.withSecurity(s.handleRegister))
 		api.Post("/login", s.withSecurity(s.handleLogin))
+		api.Post("/login/2fa", s.withSecurity(s.handleLoginTwoFactor))
+		api.Get("/oauth/providers", s.withSecurity(s.handleListOAuthProviders))
+		api.Get("/oauth/{provider}/login", s.withSecurity(s.handleOAuthLogin))
+		api.Get("/oauth/{provider}/callback", s.withSecurity(s.handleOAuthCallback))
+		api.Post("/password/forgot", s.withSecurity(s.handleForgotPassword))
+		api.Post("/password/reset", s.withSecurity(s.handleResetPassword))
+		api.Post("/email/verify", s.withSecurity(s.handleVerifyEmail))
+		api.Post("/email/resend", s.withSecurity(s.requireAuth(s.handleResendVerification)))
 		api.Post("/logout", s.withSecurity(s.requireAuth(s.handleLogout)))
 		api.Delete("/delete-account", s.withSecurity(s.requireAuth(s.handleDeleteAccount)))
 		api.Get("/me", s.withSecurity(s.requireAuth(s.handleMe)))
 		api.Put("/update-profile", s.withSecurity(s.requireAuth(s.handleUpdateProfile)))
 		api.Patch("/upload-photo", s.withSecurity(s.requireAuth(s.handleUploadPhoto)))
+		api.Post("/password/change", s.withSecurity(s.requireAuth(s.handleChangePassword)))
+		api.Get("/security-log", s.withSecurity(s.requireAuth(s.handleSecurityLog)))
+		api.Get("/sessions", s.withSecurity(s.requireAuth(s.handleListSessions)))
+		api.Post("/sessions/revoke-others", s.withSecurity(s.requireAuth(s.handleRevokeOtherSessions)))
+		api.Delete("/sessions/{id}", s.withSecurity(s.requireAuth(s.handleRevokeSession)))
+		api.Post("/2fa/setup", s.withSecurity(s.requireAuth(s.handleTwoFactorSetup)))
+		api.Post("/2fa/enable", s.withSecurity(s.requireAuth(s.handleTwoFactorEnable)))
+		api.Post("/2fa/disable", s.withSecurity(s.requireAuth(s.handleTwoFactorDisable)))
+		api.Post("/2fa/recovery-codes", s.withSecurity(s.requireAuth(s.handleRegenerateRecoveryCodes)))
 		api.Post("/save-code", s.withSecurity(s.requireAuth(s.handleSaveCode)))
-		api.Post("/run-code", s.withSecurity(s.requireAuth(runHandler)))
+		api.Get("/lessons/{lessonId}/versions", s.withSecurity(s.requireAuth(s.handleListVersions)))
+		api.Get("/lessons/{lessonId}/versions/{version}", s.withSecurity(s.requireAuth(s.handleGetVersion)))
+		api.Post("/lessons/{lessonId}/versions/{version}/restore", s.withSecurity(s.requireAuth(s.handleRestoreVersion)))
+		api.Get("/lessons/{lessonId}/diff", s.withSecurity(s.requireAuth(s.handleDiffVersions)))
+		api.Post("/lessons/{lessonId}/complete", s.withSecurity(s.requireAuth(s.handleCompleteLesson)))
+		api.Get("/progress", s.withSecurity(s.requireAuth(s.handleProgress)))
+		api.Get("/courses", s.withSecurity(s.requireAuth(s.handleListCourses)))
+		api.Get("/courses/{id}", s.withSecurity(s.requireAuth(s.handleGetCourse)))
+		api.Get("/search", s.withSecurity(s.requireAuth(s.handleSearch)))
+		api.Get("/handbooks", s.withSecurity(s.requireAuth(s.handleListHandbooks)))
+		api.Get("/handbooks/highlight.css", s.withSecurity(s.handleHandbookCSS))
+		api.Get("/handbooks/{slug}", s.withSecurity(s.requireAuth(s.handleGetHandbook)))
+		api.Post("/run-code", s.withSecurity(s.requireAuth(s.handleRun)))
+		api.Post("/run-code/stream", s.withSecurity(s.requireAuth(s.handleRunStream)))
+		api.Delete("/run-code/stream/{id}", s.withSecurity(s.requireAuth(s.handleCancelRun)))
+		api.Post("/run-code/stream/{id}/stdin", s.withSecurity(s.requireAuth(s.handleRunStdin)))
+		api.Post("/format", s.withSecurity(s.requireAuth(s.handleFormat)))
+		api.Post("/vet", s.withSecurity(s.requireAuth(s.handleVet)))
+		api.Post("/lint", s.withSecurity(s.requireAuth(s.handleLint)))
+		api.Get("/toolchains", s.withSecurity(s.requireAuth(s.handleListToolchains)))
+		api.Get("/tags", s.withSecurity(s.requireAuth(s.handleListTags)))
 		api.Get("/tasks", s.withSecurity(s.requireAuth(s.handleListTasks)))
+		api.Get("/tasks/{id}/hints", s.withSecurity(s.requireAuth(s.handleListHints)))
+		api.Post("/tasks/{id}/hints/next", s.withSecurity(s.requireAuth(s.handleNextHint)))
+		api.Get("/tasks/{id}/solution", s.withSecurity(s.requireAuth(s.handleGetSolution)))
+		api.Post("/tasks/{id}/give-up", s.withSecurity(s.requireAuth(s.handleGiveUp)))
+		api.Post("/tasks/{id}/submit", s.withSecurity(s.requireAuth(s.requireVerified(s.handleSubmitTask))))
 		api.Route("/admin", func(admin chi.Router) {
 			admin.Get("/users", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminListUsers))))
 			admin.Put("/users/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateUser))))
 			admin.Delete("/users/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteUser))))
+			admin.Get("/toolchains", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleListToolchains))))
+			admin.Put("/toolchains", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateToolchains))))
+			admin.Put("/tags", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateTags))))
+			admin.Get("/security", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminGetSecurity))))
+			admin.Put("/security", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateSecurity))))
+			admin.Get("/tasks", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminListTasks))))
 			admin.Post("/tasks", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminCreateTask))))
+			admin.Put("/tasks/order", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminReorderTasks))))
+			admin.Get("/tasks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminGetTask))))
+			admin.Put("/tasks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateTask))))
+			admin.Post("/tasks/{id}/verify", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminVerifyTask))))
+			admin.Post("/tasks/{id}/duplicate", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDuplicateTask))))
 			admin.Delete("/tasks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteTask))))
+			admin.Get("/courses", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminListCourses))))
+			admin.Post("/courses", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminCreateCourse))))
+			admin.Get("/courses/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminGetCourse))))
+			admin.Put("/courses/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateCourse))))
+			admin.Delete("/courses/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteCourse))))
+			admin.Post("/courses/{id}/modules", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminCreateModule))))
+			admin.Put("/modules/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateModule))))
+			admin.Delete("/modules/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteModule))))
+			admin.Post("/modules/{id}/lessons", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminCreateLesson))))
+			admin.Put("/lessons/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateLesson))))
+			admin.Delete("/lessons/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteLesson))))
+			admin.Get("/handbooks", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminListHandbooks))))
+			admin.Post("/handbooks", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminCreateHandbook))))
+			admin.Post("/handbooks/preview", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminPreviewHandbook))))
+			admin.Get("/handbooks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminGetHandbook))))
+			admin.Put("/handbooks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminUpdateHandbook))))
+			admin.Delete("/handbooks/{id}", s.withSecurity(s.requireAuth(s.requireAdmin(s.handleAdminDeleteHandbook))))
 		})
 	})
 
@@ -41,6 +111,8 @@ func (s *Server) setupRouter() {
 	r.Get("/login", s.serveFile("login.html"))
 	r.Get("/handbooks", s.serveFile("handbooks.html"))
 	r.Get("/registration", s.serveFile("registration.html"))
+	r.Get("/reset-password", s.serveFile("reset-password.html"))
+	r.Get("/verify-email", s.serveFile("verify-email.html"))
 	r.Get("/go", s.serveFile("go.html"))
 	r.Get("/about", s.serveFile("about.html"))
 	r.Get("/profile", s.serveFile("profile.html"))
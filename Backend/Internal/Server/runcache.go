@@ -0,0 +1,157 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// runCache remembers results of deterministic runs, keyed by a hash of
+// everything that can influence them. Entries expire after ttl and the
+// least recently used ones are evicted beyond max entries or maxBytes of
+// output. Results with more than maxEntryBytes of output are not kept.
+type runCache struct {
+	ttl           time.Duration
+	max           int
+	maxBytes      int
+	maxEntryBytes int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int
+}
+
+type runCacheEntry struct {
+	key     string
+	resp    RunResp
+	expires time.Time
+}
+
+// size approximates the memory an entry holds; the output dominates.
+func (e *runCacheEntry) size() int {
+	return len(e.key) + len(e.resp.Stdout) + len(e.resp.Stderr) + len(e.resp.GoVersion)
+}
+
+func newRunCache(ttl time.Duration, max, maxBytes, maxEntryBytes int) *runCache {
+	return &runCache{
+		ttl:           ttl,
+		max:           max,
+		maxBytes:      maxBytes,
+		maxEntryBytes: maxEntryBytes,
+		ll:            list.New(),
+		items:         make(map[string]*list.Element),
+	}
+}
+
+// newRunCacheFromEnv reads RUN_CACHE_TTL, RUN_CACHE_SIZE (entries),
+// RUN_CACHE_BYTES (total output) and RUN_CACHE_ENTRY_BYTES (output of one
+// run). A size of 0 disables caching.
+func newRunCacheFromEnv() (*runCache, error) {
+	ttl := 10 * time.Minute
+	if v := os.Getenv("RUN_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("RUN_CACHE_TTL: %w", err)
+		}
+		ttl = d
+	}
+	size, err := getenvInt("RUN_CACHE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes, err := getenvInt("RUN_CACHE_BYTES", 32<<20)
+	if err != nil {
+		return nil, err
+	}
+	maxEntryBytes, err := getenvInt("RUN_CACHE_ENTRY_BYTES", 64<<10)
+	if err != nil {
+		return nil, err
+	}
+	return newRunCache(ttl, size, maxBytes, maxEntryBytes), nil
+}
+
+func (c *runCache) get(key string) (RunResp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return RunResp{}, false
+	}
+	e := el.Value.(*runCacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return RunResp{}, false
+	}
+	c.ll.MoveToFront(el)
+	return e.resp, true
+}
+
+// put remembers resp under key. Runs that were killed, or whose output is
+// too large to keep, are skipped.
+func (c *runCache) put(key string, resp RunResp) {
+	e := &runCacheEntry{key: key, resp: resp, expires: time.Now().Add(c.ttl)}
+	if c.max <= 0 || resp.interrupted() || resp.ExitCode < 0 || e.size() > c.maxEntryBytes || e.size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.ll.PushFront(e)
+	c.bytes += e.size()
+	for c.ll.Len() > c.max || c.bytes > c.maxBytes {
+		c.remove(c.ll.Back())
+	}
+}
+
+// remove drops an entry; c.mu must be held.
+func (c *runCache) remove(el *list.Element) {
+	e := el.Value.(*runCacheEntry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= e.size()
+}
+
+// runCacheKey hashes the workspace, command, toolchain and stdin of a run.
+// Fields are length-prefixed so different inputs cannot collide by
+// shifting bytes between them.
+func runCacheKey(job RunJob, stdin string) string {
+	h := sha256.New()
+	field := func(s string) {
+		io.WriteString(h, strconv.Itoa(len(s)))
+		io.WriteString(h, ":")
+		io.WriteString(h, s)
+	}
+
+	paths := make([]string, 0, len(job.Files))
+	for p := range job.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	field(job.GoVersion)
+	field(strconv.Itoa(len(job.Cmd)))
+	for _, a := range job.Cmd {
+		field(a)
+	}
+	field(strconv.Itoa(len(paths)))
+	for _, p := range paths {
+		field(p)
+		field(job.Files[p])
+	}
+	field(stdin)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCacheKey(t *testing.T) {
+	base := RunJob{
+		Files:     map[string]string{"main.go": "package main", "util.go": "package main // util"},
+		Cmd:       []string{"go", "run", "."},
+		GoVersion: "1.22",
+	}
+	key := runCacheKey(base, "")
+
+	same := base
+	same.Files = map[string]string{"util.go": "package main // util", "main.go": "package main"}
+	if runCacheKey(same, "") != key {
+		t.Errorf("key depends on map iteration order")
+	}
+
+	variants := map[string]func(j *RunJob) string{
+		"go version": func(j *RunJob) string { j.GoVersion = "1.21"; return "" },
+		"command":    func(j *RunJob) string { j.Cmd = []string{"go", "test", "."}; return "" },
+		"content": func(j *RunJob) string {
+			j.Files = map[string]string{"main.go": "package main ", "util.go": "package main // util"}
+			return ""
+		},
+		"path": func(j *RunJob) string {
+			j.Files = map[string]string{"main.go": "package main", "util2.go": "package main // util"}
+			return ""
+		},
+		"stdin": func(j *RunJob) string { return "input" },
+		// Length-prefixed fields keep boundaries from shifting between
+		// path and content.
+		"boundary": func(j *RunJob) string {
+			j.Files = map[string]string{"main.gopackage main": "", "util.go": "package main // util"}
+			return ""
+		},
+		"arg split": func(j *RunJob) string { j.Cmd = []string{"go", "run ."}; return "" },
+	}
+	for name, change := range variants {
+		j := base
+		stdin := change(&j)
+		if runCacheKey(j, stdin) == key {
+			t.Errorf("changing the %s does not change the key", name)
+		}
+	}
+}
+
+func TestRunCacheEviction(t *testing.T) {
+	c := newRunCache(time.Minute, 2, 1<<20, 1<<10)
+
+	c.put("a", RunResp{Stdout: "a"})
+	c.put("b", RunResp{Stdout: "b"})
+	if _, ok := c.get("a"); !ok { // a is now the most recently used
+		t.Fatal("a missing")
+	}
+	c.put("c", RunResp{Stdout: "c"})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if resp, ok := c.get(key); !ok || resp.Stdout != key {
+			t.Errorf("get(%q) = %+v, %v; want it cached", key, resp, ok)
+		}
+	}
+
+	c.put("a", RunResp{Stdout: "a2"})
+	if resp, _ := c.get("a"); resp.Stdout != "a2" {
+		t.Errorf("put did not replace an existing entry, got %q", resp.Stdout)
+	}
+	if c.ll.Len() != 2 || len(c.items) != 2 {
+		t.Errorf("cache holds %d/%d entries, want 2", c.ll.Len(), len(c.items))
+	}
+}
+
+func TestRunCacheExpiry(t *testing.T) {
+	c := newRunCache(-time.Second, 10, 1<<20, 1<<10)
+	c.put("a", RunResp{Stdout: "a"})
+	if _, ok := c.get("a"); ok {
+		t.Error("expired entry returned")
+	}
+	if c.ll.Len() != 0 || c.bytes != 0 {
+		t.Errorf("expired entry kept: %d entries, %d bytes", c.ll.Len(), c.bytes)
+	}
+}
+
+func TestRunCacheBytes(t *testing.T) {
+	out := strings.Repeat("x", 100)
+	c := newRunCache(time.Minute, 100, 350, 200)
+
+	c.put("big", RunResp{Stdout: strings.Repeat("x", 300)})
+	if _, ok := c.get("big"); ok {
+		t.Error("entry over the per-entry limit was cached")
+	}
+
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		c.put(key, RunResp{Stdout: out})
+		if c.bytes > 350 {
+			t.Fatalf("cache holds %d bytes, over its 350 byte budget", c.bytes)
+		}
+	}
+	if _, ok := c.get("k1"); ok {
+		t.Error("oldest entry kept beyond the byte budget")
+	}
+	if _, ok := c.get("k4"); !ok {
+		t.Error("newest entry evicted")
+	}
+
+	total := 0
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		total += el.Value.(*runCacheEntry).size()
+	}
+	if total != c.bytes {
+		t.Errorf("byte count = %d, entries hold %d", c.bytes, total)
+	}
+}
+
+func TestRunCacheSkipsInterruptedRuns(t *testing.T) {
+	c := newRunCache(time.Minute, 10, 1<<20, 1<<10)
+	runs := map[string]RunResp{
+		// docker rm -f on timeout leaves the client with exit code 137.
+		"timed out": {Stderr: "\nprogram timed out\n", ExitCode: 137, TimedOut: true},
+		"cancelled": {ExitCode: 137, Cancelled: true},
+		"not run":   {ExitCode: -1},
+	}
+	for name, resp := range runs {
+		c.put(name, resp)
+		if _, ok := c.get(name); ok {
+			t.Errorf("%s run was cached", name)
+		}
+	}
+
+	c.put("exited", RunResp{Stdout: "partial", ExitCode: 2})
+	if _, ok := c.get("exited"); !ok {
+		t.Error("run that exited on its own was not cached")
+	}
+}
+
+func TestRunCacheDisabled(t *testing.T) {
+	c := newRunCache(time.Minute, 0, 1<<20, 1<<10)
+	c.put("a", RunResp{Stdout: "a"})
+	if _, ok := c.get("a"); ok {
+		t.Error("cache of size 0 stored an entry")
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Runner executes a Go command inside some sandbox and reports what the
+// program printed. Implementations own the workspace they write Files to.
+type Runner interface {
+	Run(ctx context.Context, job RunJob) (RunResp, error)
+}
+
+// RunJob describes a single sandboxed invocation.
+type RunJob struct {
+	// Files maps workspace-relative paths to their contents.
+	Files map[string]string
+	// Cmd is the command executed in the workspace, e.g. go run .
+	Cmd []string
+	// GoVersion selects the toolchain, e.g. 1.22. Empty means the
+	// runner's default.
+	GoVersion string
+	// Stdin, when set, is connected to the program's standard input.
+	Stdin io.Reader
+	// Stdout and Stderr, when set, receive output as it is produced in
+	// addition to it being collected into the RunResp.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// outputs returns the writers a runner should attach to the process.
+func (j RunJob) outputs(outb, errb io.Writer) (stdout, stderr io.Writer) {
+	stdout, stderr = outb, errb
+	if j.Stdout != nil {
+		stdout = io.MultiWriter(outb, j.Stdout)
+	}
+	if j.Stderr != nil {
+		stderr = io.MultiWriter(errb, j.Stderr)
+	}
+	return stdout, stderr
+}
+
+// newRunner picks the execution backend from the environment.
+func newRunner() (Runner, error) {
+	timeout := runTimeout
+	if v := os.Getenv("RUN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("RUN_TIMEOUT: %w", err)
+		}
+		timeout = d
+	}
+
+	switch kind := getenv("RUNNER", "docker"); kind {
+	case "docker":
+		return &DockerRunner{
+			Image:   getenv("RUNNER_IMAGE", "golang"),
+			Timeout: timeout,
+		}, nil
+	case "local":
+		memMB, err := getenvInt("RUNNER_MEMORY_MB", 4096)
+		if err != nil {
+			return nil, err
+		}
+		toolchains, err := parseToolchains(os.Getenv("RUNNER_GO_TOOLCHAINS"))
+		if err != nil {
+			return nil, err
+		}
+		return &LocalRunner{
+			GoBin:      getenv("RUNNER_GO", "go"),
+			Toolchains: toolchains,
+			User:       os.Getenv("RUNNER_USER"),
+			MemoryMB:   memMB,
+			Timeout:    timeout,
+		}, nil
+	case "fake":
+		return &FakeRunner{}, nil
+	default:
+		return nil, fmt.Errorf("unknown RUNNER %q", kind)
+	}
+}
+
+// parseToolchains reads a "1.21=/opt/go1.21/bin/go,1.22=..." list mapping
+// Go versions to go binaries for the local runner.
+func parseToolchains(v string) (map[string]string, error) {
+	m := map[string]string{}
+	if v == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		ver, bin, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !goVersionRegex.MatchString(ver) || bin == "" {
+			return nil, fmt.Errorf("RUNNER_GO_TOOLCHAINS: bad entry %q", pair)
+		}
+		m[ver] = bin
+	}
+	return m, nil
+}
+
+// writeWorkspace materialises job files under dir, refusing paths that
+// would escape it.
+func writeWorkspace(dir string, files map[string]string) error {
+	for name, content := range files {
+		clean := filepath.Clean(filepath.FromSlash(name))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %q", name)
+		}
+		p := filepath.Join(dir, clean)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachStdin connects r to the command's standard input. Copying happens
+// in a goroutine the command does not own, so an interactive stream that
+// never reaches EOF cannot hold up cmd.Wait once the program has exited.
+func attachStdin(cmd *exec.Cmd, r io.Reader) error {
+	if r == nil {
+		return nil
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		_, _ = io.Copy(w, r)
+		_ = w.Close()
+	}()
+	return nil
+}
+
+// exitCodeOf converts the error returned by exec.Cmd.Run into a process
+// exit code. A nil error is 0; anything that is not an exit status is -1.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee interface{ ExitCode() int }
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	}
+	return -1
+}
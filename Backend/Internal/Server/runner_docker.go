@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// DockerRunner runs each job in a throwaway container with the workspace
+// bind-mounted at /work. The job's Go version is used as the image tag.
+type DockerRunner struct {
+	Image   string
+	Timeout time.Duration
+}
+
+func (d *DockerRunner) Run(ctx context.Context, job RunJob) (RunResp, error) {
+	dir, err := os.MkdirTemp("", "gorun-*")
+	if err != nil {
+		return RunResp{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeWorkspace(dir, job.Files); err != nil {
+		return RunResp{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	// Killing the docker client does not stop the container, so it gets a
+	// known name that cancellation can remove.
+	name := "goedu-" + filepath.Base(dir)
+	args := []string{
+		"run", "--rm",
+		"--name", name,
+		"--network", "none",
+		"-v", dir + ":/work",
+		"-w", "/work",
+	}
+	if job.Stdin != nil {
+		args = append(args, "-i")
+	}
+	tag := job.GoVersion
+	if tag == "" {
+		tag = "latest"
+	}
+	args = append(args, d.Image+":"+tag)
+	args = append(args, job.Cmd...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var outb, errb bytes.Buffer
+	cmd.Stdout, cmd.Stderr = job.outputs(&outb, &errb)
+	cmd.WaitDelay = time.Second
+	cmd.Cancel = func() error {
+		_ = exec.Command("docker", "rm", "-f", name).Run()
+		return cmd.Process.Kill()
+	}
+
+	if err := attachStdin(cmd, job.Stdin); err != nil {
+		return RunResp{}, err
+	}
+
+	runErr := cmd.Run()
+	var execErr *exec.Error
+	if errors.As(runErr, &execErr) {
+		return RunResp{}, runErr
+	}
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		errb.WriteString("\nprogram timed out\n")
+	}
+
+	return RunResp{
+		Stdout:    outb.String(),
+		Stderr:    errb.String(),
+		ExitCode:  exitCodeOf(runErr),
+		TimedOut:  timedOut,
+		Cancelled: errors.Is(ctx.Err(), context.Canceled),
+	}, nil
+}
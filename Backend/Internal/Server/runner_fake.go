@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// FakeRunner never executes anything. It records every job and answers
+// with Resp/Err, or with Func when set, which makes it useful in tests and
+// for running the server on machines without a Go sandbox.
+type FakeRunner struct {
+	Resp RunResp
+	Err  error
+	Func func(RunJob) (RunResp, error)
+
+	mu   sync.Mutex
+	jobs []RunJob
+}
+
+func (f *FakeRunner) Run(ctx context.Context, job RunJob) (RunResp, error) {
+	f.mu.Lock()
+	f.jobs = append(f.jobs, job)
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return RunResp{}, err
+	}
+	resp, err := f.Resp, f.Err
+	if f.Func != nil {
+		resp, err = f.Func(job)
+	}
+	if err == nil {
+		if job.Stdout != nil {
+			_, _ = io.WriteString(job.Stdout, resp.Stdout)
+		}
+		if job.Stderr != nil {
+			_, _ = io.WriteString(job.Stderr, resp.Stderr)
+		}
+	}
+	return resp, err
+}
+
+// Jobs returns a copy of the jobs seen so far.
+func (f *FakeRunner) Jobs() []RunJob {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RunJob(nil), f.jobs...)
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// LocalRunner executes jobs directly on the host for environments without
+// Docker. Each run gets its own GOPATH and build cache, runs under resource
+// limits and, when User is set, as that unprivileged account.
+//
+// MemoryMB caps virtual address space rather than resident memory; the Go
+// runtime reserves large arenas up front, so values below ~1GB break it.
+type LocalRunner struct {
+	GoBin string
+	// Toolchains maps Go versions to go binaries. Once any are listed,
+	// jobs asking for another version fail; with none, GoBin runs
+	// everything.
+	Toolchains map[string]string
+	User       string
+	MemoryMB   int
+	Timeout    time.Duration
+}
+
+func (l *LocalRunner) Run(ctx context.Context, job RunJob) (RunResp, error) {
+	if len(job.Cmd) == 0 {
+		return RunResp{}, errors.New("empty command")
+	}
+
+	dir, err := os.MkdirTemp("", "gorun-*")
+	if err != nil {
+		return RunResp{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		return RunResp{}, err
+	}
+	if err := writeWorkspace(work, job.Files); err != nil {
+		return RunResp{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+	defer cancel()
+
+	argv := append([]string(nil), job.Cmd...)
+	if argv[0] == "go" {
+		argv[0] = l.GoBin
+		if job.GoVersion != "" && len(l.Toolchains) > 0 {
+			bin, ok := l.Toolchains[job.GoVersion]
+			if !ok {
+				return RunResp{}, fmt.Errorf("no local toolchain for go %s", job.GoVersion)
+			}
+			argv[0] = bin
+		}
+	}
+	if p, err := exec.LookPath(argv[0]); err == nil {
+		argv[0] = p
+	}
+
+	// ulimit applies to the shell, which then execs the real command so
+	// the limits are inherited by the go tool and the program it builds.
+	limits := fmt.Sprintf(
+		"ulimit -v %d && ulimit -t %d && ulimit -f %d && exec \"$@\"",
+		l.MemoryMB*1024,
+		int(l.Timeout.Seconds())+1,
+		64*1024,
+	)
+	cmd := exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", limits, "sh"}, argv...)...)
+	cmd.Dir = work
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"GOPATH=" + filepath.Join(dir, "gopath"),
+		"GOCACHE=" + filepath.Join(dir, "cache"),
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+		"GOPROXY=off",
+	}
+
+	var outb, errb bytes.Buffer
+	cmd.Stdout, cmd.Stderr = job.outputs(&outb, &errb)
+	cmd.WaitDelay = time.Second
+
+	if err := sandboxProcess(cmd, dir, l.User); err != nil {
+		return RunResp{}, err
+	}
+
+	if err := attachStdin(cmd, job.Stdin); err != nil {
+		return RunResp{}, err
+	}
+
+	runErr := cmd.Run()
+	var execErr *exec.Error
+	if errors.As(runErr, &execErr) {
+		return RunResp{}, runErr
+	}
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		errb.WriteString("\nprogram timed out\n")
+	}
+
+	return RunResp{
+		Stdout:    outb.String(),
+		Stderr:    errb.String(),
+		ExitCode:  exitCodeOf(runErr),
+		TimedOut:  timedOut,
+		Cancelled: errors.Is(ctx.Err(), context.Canceled),
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func sandboxProcess(cmd *exec.Cmd, dir, username string) error {
+	if username != "" {
+		return errors.New("RUNNER_USER is only supported on unix")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLocalRunner(timeout time.Duration) *LocalRunner {
+	return &LocalRunner{GoBin: "go", MemoryMB: 4096, Timeout: timeout}
+}
+
+func TestLocalRunner(t *testing.T) {
+	var streamed strings.Builder
+	resp, err := testLocalRunner(10*time.Second).Run(context.Background(), RunJob{
+		Files:  map[string]string{"data/input.txt": "from the workspace\n"},
+		Cmd:    []string{"sh", "-c", "cat data/input.txt; cat; echo oops >&2; exit 3"},
+		Stdin:  strings.NewReader("from stdin\n"),
+		Stdout: &streamed,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := "from the workspace\nfrom stdin\n"; resp.Stdout != want || streamed.String() != want {
+		t.Errorf("stdout = %q, streamed %q; want %q", resp.Stdout, streamed.String(), want)
+	}
+	if resp.Stderr != "oops\n" || resp.ExitCode != 3 || resp.interrupted() {
+		t.Errorf("Run = %+v; want stderr oops and exit code 3", resp)
+	}
+}
+
+func TestLocalRunnerTimeoutKillsProcessGroup(t *testing.T) {
+	start := time.Now()
+	// The background sleep stands in for the binary go run spawns; it
+	// holds stdout open, so Run only returns once the group is killed.
+	resp, err := testLocalRunner(300*time.Millisecond).Run(context.Background(), RunJob{
+		Cmd: []string{"sh", "-c", "sleep 30 & echo started; wait"},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Run took %v after a 300ms timeout", d)
+	}
+	if resp.Stdout != "started\n" || !strings.Contains(resp.Stderr, "program timed out") {
+		t.Errorf("Run = %+v, want the output so far and a timeout note", resp)
+	}
+	if resp.ExitCode == 0 || !resp.TimedOut || resp.Cancelled {
+		t.Errorf("killed run reported exit code %d, timedOut %v, cancelled %v", resp.ExitCode, resp.TimedOut, resp.Cancelled)
+	}
+}
+
+func TestLocalRunnerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	resp, err := testLocalRunner(time.Minute).Run(ctx, RunJob{Cmd: []string{"sleep", "30"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("cancelled run took %v", d)
+	}
+	if resp.ExitCode == 0 || !resp.Cancelled || resp.TimedOut || strings.Contains(resp.Stderr, "timed out") {
+		t.Errorf("cancelled run = %+v, want a killed run that did not time out", resp)
+	}
+}
+
+func TestLocalRunnerToolchains(t *testing.T) {
+	l := testLocalRunner(time.Second)
+	l.Toolchains = map[string]string{"1.22": "/opt/go1.22/bin/go"}
+	if _, err := l.Run(context.Background(), RunJob{Cmd: []string{"go", "version"}, GoVersion: "1.21"}); err == nil {
+		t.Error("ran a Go version with no configured toolchain")
+	}
+	if _, err := l.Run(context.Background(), RunJob{}); err == nil {
+		t.Error("ran an empty command")
+	}
+}
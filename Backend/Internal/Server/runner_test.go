@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	_ Runner = (*DockerRunner)(nil)
+	_ Runner = (*LocalRunner)(nil)
+	_ Runner = (*FakeRunner)(nil)
+)
+
+func TestFakeRunner(t *testing.T) {
+	var stdout, stderr strings.Builder
+	f := &FakeRunner{Resp: RunResp{Stdout: "out", Stderr: "err", ExitCode: 2}}
+	job := RunJob{Cmd: []string{"go", "run", "."}, Stdout: &stdout, Stderr: &stderr}
+
+	resp, err := f.Run(context.Background(), job)
+	if err != nil || resp.ExitCode != 2 {
+		t.Fatalf("Run = %+v, %v", resp, err)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("streamed %q, %q; want the response output", stdout.String(), stderr.String())
+	}
+
+	f.Func = func(j RunJob) (RunResp, error) { return RunResp{Stdout: strings.Join(j.Cmd, " ")}, nil }
+	if resp, _ := f.Run(context.Background(), RunJob{Cmd: []string{"go", "vet"}}); resp.Stdout != "go vet" {
+		t.Errorf("Func not used: %+v", resp)
+	}
+
+	boom := errors.New("boom")
+	f.Func, f.Err = nil, boom
+	if _, err := f.Run(context.Background(), job); !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want Err", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Run(ctx, job); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run on a cancelled context = %v", err)
+	}
+
+	if n := len(f.Jobs()); n != 4 {
+		t.Errorf("recorded %d jobs, want 4", n)
+	}
+}
+
+func TestNewRunner(t *testing.T) {
+	t.Setenv("RUN_TIMEOUT", "")
+	t.Setenv("RUNNER_GO_TOOLCHAINS", "")
+
+	t.Setenv("RUNNER", "")
+	r, err := newRunner()
+	if d, ok := r.(*DockerRunner); err != nil || !ok || d.Image != "golang" || d.Timeout != runTimeout {
+		t.Errorf("default runner = %#v, %v; want docker with the default timeout", r, err)
+	}
+
+	t.Setenv("RUNNER", "local")
+	t.Setenv("RUN_TIMEOUT", "3s")
+	t.Setenv("RUNNER_GO_TOOLCHAINS", "1.21=/opt/go1.21/bin/go, 1.22=/opt/go1.22/bin/go")
+	r, err = newRunner()
+	l, ok := r.(*LocalRunner)
+	if err != nil || !ok {
+		t.Fatalf("RUNNER=local: %#v, %v", r, err)
+	}
+	want := map[string]string{"1.21": "/opt/go1.21/bin/go", "1.22": "/opt/go1.22/bin/go"}
+	if l.Timeout != 3*time.Second || l.GoBin != "go" || !reflect.DeepEqual(l.Toolchains, want) {
+		t.Errorf("local runner = %+v", l)
+	}
+
+	t.Setenv("RUNNER", "fake")
+	if r, err := newRunner(); err != nil {
+		t.Errorf("RUNNER=fake: %v", err)
+	} else if _, ok := r.(*FakeRunner); !ok {
+		t.Errorf("RUNNER=fake gave %T", r)
+	}
+
+	bad := map[string][2]string{
+		"unknown runner":    {"RUNNER", "vm"},
+		"bad timeout":       {"RUN_TIMEOUT", "ten seconds"},
+		"bad toolchain":     {"RUNNER_GO_TOOLCHAINS", "go1.22=/usr/bin/go"},
+		"toolchain sans go": {"RUNNER_GO_TOOLCHAINS", "1.22="},
+	}
+	for name, kv := range bad {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("RUNNER", "local")
+			t.Setenv(kv[0], kv[1])
+			if _, err := newRunner(); err == nil {
+				t.Errorf("%s=%q accepted", kv[0], kv[1])
+			}
+		})
+	}
+}
+
+func TestWriteWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module play\n",
+		"main.go":         "package main\n",
+		"util/strings.go": "package util\n",
+		"./a/../b.txt":    "b",
+	}
+	if err := writeWorkspace(dir, files); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"go.mod":          "module play\n",
+		"main.go":         "package main\n",
+		"util/strings.go": "package util\n",
+		"b.txt":           "b",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{"../escape.go", "a/../../escape.go", "/etc/passwd", ".."} {
+		if err := writeWorkspace(t.TempDir(), map[string]string{name: "x"}); err == nil {
+			t.Errorf("path %q accepted", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.go")); err == nil {
+		t.Error("a file was written outside the workspace")
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	if got := exitCodeOf(nil); got != 0 {
+		t.Errorf("exitCodeOf(nil) = %d", got)
+	}
+	if got := exitCodeOf(errors.New("start failed")); got != -1 {
+		t.Errorf("exitCodeOf(other) = %d, want -1", got)
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	if got := exitCodeOf(exec.Command("sh", "-c", "exit 3").Run()); got != 3 {
+		t.Errorf("exitCodeOf(exit 3) = %d", got)
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	searchLimit    = 20
+	maxSearchQuery = 200
+	// snippetRadius is how many bytes of context a snippet keeps on each
+	// side of the first match.
+	snippetRadius = 80
+)
+
+// searchScore sorts by the relevance Mongo computed for a $text query.
+var searchScore = bson.M{"$meta": "textScore"}
+
+// searchTerms returns the words of a query to highlight. Negated words
+// ("-foo") are excluded because they never appear in results.
+func searchTerms(q string) []string {
+	var terms []string
+	for _, tok := range strings.Fields(q) {
+		if strings.HasPrefix(tok, "-") {
+			continue
+		}
+		terms = append(terms, strings.FieldsFunc(tok, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return terms
+}
+
+// termRegex matches any word starting with one of terms, so a search for
+// "channel" also marks "channels" the way Mongo's stemming finds it.
+func termRegex(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\w*`)
+}
+
+// snippet cuts the text around the first match of re and returns it as
+// HTML with every match wrapped in <mark>.
+func snippet(text string, re *regexp.Regexp) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	at := 0
+	if re != nil {
+		if loc := re.FindStringIndex(text); loc != nil {
+			at = loc[0]
+		}
+	}
+	start := max(0, at-snippetRadius)
+	end := min(len(text), start+2*snippetRadius)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	window := text[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	if re != nil {
+		for _, m := range re.FindAllStringIndex(window, -1) {
+			b.WriteString(html.EscapeString(window[last:m[0]]))
+			b.WriteString("<mark>" + html.EscapeString(window[m[0]:m[1]]) + "</mark>")
+			last = m[1]
+		}
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func (s *Server) searchTasks(ctx context.Context, q, tag string, re *regexp.Regexp) ([]SearchResult, error) {
+	filter := bson.M{"$text": bson.M{"$search": q}, "status": taskPublished}
+	if tag != "" {
+		filter["tags"] = tag
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"title": 1, "tags": 1, "description": 1, "score": searchScore}).
+		SetSort(bson.M{"score": searchScore}).
+		SetLimit(searchLimit)
+
+	cur, err := s.tasks.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		Task  `bson:",inline"`
+		Score float64 `bson:"score"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(docs))
+	for _, d := range docs {
+		results = append(results, SearchResult{
+			Type:    "task",
+			ID:      d.ID.Hex(),
+			Title:   d.Title,
+			Tags:    d.Tags,
+			Snippet: snippet(d.Description, re),
+			Score:   d.Score,
+		})
+	}
+	return results, nil
+}
+
+// searchHandbooks finds matching pages and points each result at the
+// section with the most matches.
+func (s *Server) searchHandbooks(ctx context.Context, q string, re *regexp.Regexp) ([]SearchResult, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"slug": 1, "title": 1, "markdown": 1, "score": searchScore}).
+		SetSort(bson.M{"score": searchScore}).
+		SetLimit(searchLimit)
+
+	cur, err := s.handbooks.Find(ctx, bson.M{"$text": bson.M{"$search": q}, "status": taskPublished}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		HandbookPage `bson:",inline"`
+		Score        float64 `bson:"score"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(docs))
+	for _, d := range docs {
+		res := SearchResult{
+			Type:  "handbook",
+			ID:    d.ID.Hex(),
+			Slug:  d.Slug,
+			Title: d.Title,
+			Score: d.Score,
+		}
+
+		best, hits := markdownSection{Text: d.Markdown}, -1
+		for _, sec := range markdownSections(d.Markdown) {
+			n := 0
+			if re != nil {
+				n = len(re.FindAllStringIndex(sec.Title+" "+sec.Text, -1))
+			}
+			if n > hits {
+				best, hits = sec, n
+			}
+		}
+		res.Section = best.Title
+		res.Anchor = best.ID
+		res.Snippet = snippet(best.Text, re)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// handleSearch answers /api/search?q=...&type=task|handbook&tag=... with
+// published tasks and handbook sections ranked by relevance. A tag filter
+// applies to tasks only, so it leaves handbooks out.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		http.Error(w, "q required", http.StatusBadRequest)
+		return
+	}
+	if len(q) > maxSearchQuery {
+		http.Error(w, "q too long", http.StatusBadRequest)
+		return
+	}
+	typ := query.Get("type")
+	if typ != "" && typ != "task" && typ != "handbook" {
+		http.Error(w, "type must be task or handbook", http.StatusBadRequest)
+		return
+	}
+	tag := strings.TrimSpace(query.Get("tag"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	re := termRegex(searchTerms(q))
+	results := []SearchResult{}
+
+	if typ != "handbook" {
+		tasks, err := s.searchTasks(ctx, q, tag, re)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, tasks...)
+	}
+	if typ != "task" && tag == "" {
+		pages, err := s.searchHandbooks(ctx, q, re)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, pages...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > searchLimit {
+		results = results[:searchLimit]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"query": q, "results": results})
+}
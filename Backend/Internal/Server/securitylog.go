@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Security log event types.
+const (
+	eventPasswordChanged   = "password_changed"
+	eventPasswordReset     = "password_reset"
+	eventEmailChanged      = "email_changed"
+	eventTwoFactorEnabled  = "two_factor_enabled"
+	eventTwoFactorDisabled = "two_factor_disabled"
+)
+
+// logSecurityEvent records a change to the user's credentials, made by the
+// request r. The change has already happened, so a failure to record it
+// is logged rather than reported to the user.
+func (s *Server) logSecurityEvent(ctx context.Context, r *http.Request, userID primitive.ObjectID, typ string) {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	_, err := s.securityLog.InsertOne(ctx, securityEventDoc{
+		UserID:    userID,
+		Type:      typ,
+		IP:        clientIP(r),
+		UserAgent: ua,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("recording %s for user %s failed: %v", typ, userID.Hex(), err)
+	}
+}
+
+func (s *Server) handleSecurityLog(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.securityLog.Find(ctx, bson.M{"userId": u.ID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(securityLogLimit))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var docs []securityEventDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]SecurityEventResp, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, SecurityEventResp{
+			Type:      d.Type,
+			IP:        d.IP,
+			UserAgent: d.UserAgent,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -6,22 +6,28 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Server struct {
-	client           *mongo.Client
-	db               *mongo.Database
-	users            *mongo.Collection
-	sessions         *mongo.Collection
-	code_submissions *mongo.Collection
-	tasks            *mongo.Collection
-	staticDir        string
-	devMode          bool
+	*Store
+
+	staticDir      string
+	devMode        bool
+	runner         Runner
+	queue          *runQueue
+	runCache       *runCache
+	goimports      string
+	staticcheck    string
+	mailer         Mailer
+	appURL         string
+	oauthProviders map[string]*oauthProvider
 
 	rateMu   sync.Mutex
 	rateByIP map[string][]time.Time
 
+	liveMu   sync.Mutex
+	liveRuns map[string]*liveRun
+
 	emailRegex *regexp.Regexp
 	router     *chi.Mux
 }
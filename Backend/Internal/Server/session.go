@@ -2,9 +2,6 @@ package server
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"net/http"
 	"time"
@@ -12,34 +9,55 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (s *Server) createSession(w http.ResponseWriter, userID primitive.ObjectID) error {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
+// createSession starts a session for the device making r. It expires
+// after sessionTTL without activity, and after sessionMaxAge regardless.
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) error {
+	return s.startSession(w, r, userID, sessionTTL, sessionMaxAge, false)
+}
+
+// createPendingSession starts a short session that can only be traded for
+// a full one by passing the second factor.
+func (s *Server) createPendingSession(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) error {
+	return s.startSession(w, r, userID, pendingLoginTTL, pendingLoginTTL, true)
+}
+
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID, ttl, maxAge time.Duration, pending bool) error {
+	raw, hash, err := newToken()
+	if err != nil {
 		return err
 	}
 
-	raw := base64.RawURLEncoding.EncodeToString(b)
-	th := sha256.Sum256([]byte(raw))
-
 	now := time.Now().UTC()
-	exp := now.Add(sessionTTL)
+	exp := now.Add(ttl)
+	maxExp := now.Add(maxAge)
 
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
 	doc := sessionDoc{
-		UserID:    userID,
-		TokenHash: th[:],
-		ExpiresAt: exp,
-		CreatedAt: now,
+		UserID:       userID,
+		TokenHash:    hash,
+		UserAgent:    ua,
+		IP:           clientIP(r),
+		Pending:      pending,
+		ExpiresAt:    exp,
+		MaxExpiresAt: maxExp,
+		LastSeenAt:   now,
+		CreatedAt:    now,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := s.sessions.InsertOne(ctx, doc)
+	_, err = s.sessions.InsertOne(ctx, doc)
 	if err != nil {
 		return err
 	}
 
-	setCookie(w, raw, exp, s.devMode)
+	// The cookie lives as long as the session could; the idle limit is
+	// enforced on the server, which slides ExpiresAt on activity.
+	setCookie(w, raw, maxExp, s.devMode)
 	return nil
 }
 
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// touchSession records activity on sess and slides its expiry forward,
+// never past its absolute limit. Sessions from before the limit existed
+// get one counted from their creation.
+func (s *Server) touchSession(ctx context.Context, sess *sessionDoc, r *http.Request, now time.Time) {
+	maxExp := sess.MaxExpiresAt
+	if maxExp.IsZero() {
+		maxExp = sess.CreatedAt.Add(sessionMaxAge)
+	}
+	exp := now.Add(sessionTTL)
+	if exp.After(maxExp) {
+		exp = maxExp
+	}
+
+	sess.LastSeenAt, sess.ExpiresAt, sess.MaxExpiresAt = now, exp, maxExp
+	sess.IP = clientIP(r)
+	_, err := s.sessions.UpdateByID(ctx, sess.ID, bson.M{"$set": bson.M{
+		"lastSeenAt":   now,
+		"expiresAt":    exp,
+		"maxExpiresAt": maxExp,
+		"ip":           sess.IP,
+	}})
+	if err != nil {
+		log.Printf("touching session failed: %v", err)
+	}
+}
+
+// revokeOtherSessions signs the user out everywhere except keep.
+func (s *Server) revokeOtherSessions(ctx context.Context, userID, keep primitive.ObjectID) (int64, error) {
+	res, err := s.sessions.DeleteMany(ctx, bson.M{"userId": userID, "_id": bson.M{"$ne": keep}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	current := r.Context().Value(ctxSessionKey{}).(sessionDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	cur, err := s.sessions.Find(ctx,
+		bson.M{
+			"userId":    u.ID,
+			"pending":   bson.M{"$ne": true},
+			"expiresAt": bson.M{"$gt": time.Now().UTC()},
+		},
+		options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}}),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var docs []sessionDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]SessionResp, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, SessionResp{
+			ID:         d.ID.Hex(),
+			UserAgent:  d.UserAgent,
+			IP:         d.IP,
+			CreatedAt:  d.CreatedAt,
+			LastSeenAt: d.LastSeenAt,
+			ExpiresAt:  d.ExpiresAt,
+			Current:    d.ID == current.ID,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRevokeSession signs out one of the user's sessions. Revoking the
+// current one is the same as logging out.
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	current := r.Context().Value(ctxSessionKey{}).(sessionDoc)
+
+	id, ok := objectIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.sessions.DeleteOne(ctx, bson.M{"_id": id, "userId": u.ID})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if id == current.ID {
+		clearCookie(w, s.devMode)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	current := r.Context().Value(ctxSessionKey{}).(sessionDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	n, err := s.revokeOtherSessions(ctx, u.ID, current.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"revoked": n})
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// revealAfter is how many failed attempts a student needs before they may
+// give up on the task and see its solution.
+func (t Task) revealAfter() int {
+	if t.RevealAfter > 0 {
+		return t.RevealAfter
+	}
+	return defaultRevealAfter
+}
+
+func (t Task) hasSolution() bool {
+	return len(t.Solution) > 0 || t.Editorial != ""
+}
+
+// solutionUnlocked reports whether the student may see the solution: they
+// passed the task or gave up on it.
+func solutionUnlocked(p taskProgressDoc) bool {
+	return p.FirstPassedAt != nil || p.GaveUpAt != nil
+}
+
+// handleGetSolution returns a task's reference solution and editorial once
+// the caller has unlocked them.
+func (s *Server) handleGetSolution(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findPublishedTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	if !task.hasSolution() {
+		http.Error(w, "Task has no solution", http.StatusNotFound)
+		return
+	}
+	p, err := s.findProgress(ctx, u.ID, task.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !solutionUnlocked(p) {
+		http.Error(w, "Pass the task or give up to see its solution", http.StatusForbidden)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SolutionResp{Solution: task.Solution, Editorial: task.Editorial})
+}
+
+// handleGiveUp unlocks the solution for a student who has failed the task
+// enough times. Giving up is recorded and cannot be undone.
+func (s *Server) handleGiveUp(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findPublishedTask(ctx, w, r)
+	if !ok {
+		return
+	}
+	if !task.hasSolution() {
+		http.Error(w, "Task has no solution", http.StatusNotFound)
+		return
+	}
+	p, err := s.findProgress(ctx, u.ID, task.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !solutionUnlocked(p) {
+		if p.FailedAttempts < task.revealAfter() {
+			msg := fmt.Sprintf("You can give up after %d failed attempts (%d so far)", task.revealAfter(), p.FailedAttempts)
+			http.Error(w, msg, http.StatusForbidden)
+			return
+		}
+		_, err := s.progress.UpdateOne(ctx,
+			bson.M{"userId": u.ID, "taskId": task.ID, "gaveUpAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"gaveUpAt": time.Now().UTC()}},
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, SolutionResp{Solution: task.Solution, Editorial: task.Editorial})
+}
+
+// handleAdminVerifyTask grades the reference solution against the task's
+// tests, showing the task can be solved.
+func (s *Server) handleAdminVerifyTask(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	task, ok := s.findTaskParam(ctx, w, r)
+	if !ok {
+		return
+	}
+	if len(task.Tests) == 0 {
+		http.Error(w, "Task has no tests", http.StatusConflict)
+		return
+	}
+	if len(task.Solution) == 0 {
+		http.Error(w, "Task has no reference solution", http.StatusConflict)
+		return
+	}
+
+	goVersion, err := s.resolveGoVersion(r.Context(), "", &task)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+	files, err := buildWorkspace("", task.Solution, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.gradeFiles(r.Context(), u.ID, task, goVersion, files)
+	if errors.Is(err, errQueueFull) || errors.Is(err, errUserBusy) {
+		writeQueueError(w, err)
+		return
+	}
+	if err != nil {
+		log.Printf("verifying task %s failed: %v", task.ID.Hex(), err)
+		http.Error(w, "run failed", http.StatusInternalServerError)
+		return
+	}
+	result.Score = taskScore(result)
+	writeJSON(w, http.StatusOK, result)
+}
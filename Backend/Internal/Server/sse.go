@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// sseWriter serialises Server-Sent Events onto a response. It is safe for
+// concurrent use, since stdout and stderr are copied by separate goroutines.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	f  http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return &sseWriter{w: w, f: f}, true
+}
+
+func (s *sseWriter) send(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
+
+// stream returns an io.Writer that forwards each chunk as an event.
+func (s *sseWriter) stream(event string) *sseStream {
+	return &sseStream{sse: s, event: event}
+}
+
+type sseStream struct {
+	sse     *sseWriter
+	event   string
+	pending []byte
+}
+
+// Write sends p as a {"data": ...} event. A multi-byte character split
+// across writes is held back until it is complete.
+func (s *sseStream) Write(p []byte) (int, error) {
+	buf := append(s.pending, p...)
+	cut := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	s.pending = append([]byte(nil), buf[cut:]...)
+	if cut == 0 {
+		return len(p), nil
+	}
+	if err := s.sse.send(s.event, map[string]string{"data": string(buf[:cut])}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush sends the bytes Write is still holding back, with the incomplete
+// character replaced by U+FFFD. Call it once the output has ended.
+func (s *sseStream) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	data := strings.ToValidUTF8(string(s.pending), "\uFFFD")
+	s.pending = nil
+	return s.sse.send(s.event, map[string]string{"data": data})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Store is the database side of the server: its collections and the
+// settings content validation depends on. Tools that only manage content,
+// like cmd/content, use it without the runner, mailer and login providers
+// the HTTP server needs.
+type Store struct {
+	client           *mongo.Client
+	db               *mongo.Database
+	users            *mongo.Collection
+	sessions         *mongo.Collection
+	code_submissions *mongo.Collection
+	tasks            *mongo.Collection
+	settings         *mongo.Collection
+	courses          *mongo.Collection
+	modules          *mongo.Collection
+	lessons          *mongo.Collection
+	completions      *mongo.Collection
+	progress         *mongo.Collection
+	handbooks        *mongo.Collection
+	tokens           *mongo.Collection
+	oauthStates      *mongo.Collection
+	securityLog      *mongo.Collection
+	goVersion        string
+}
+
+// NewStore connects to MONGODB_URI and brings the database up to date.
+func NewStore(ctx context.Context) (*Store, error) {
+	_ = godotenv.Load()
+
+	goVersion := getenv("GO_VERSION", "1.22")
+	if !goVersionRegex.MatchString(goVersion) {
+		return nil, fmt.Errorf("GO_VERSION: invalid version %q", goVersion)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(cctx, options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(cctx, readpref.Primary()); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, err
+	}
+
+	db := client.Database(getenv("MONGODB_DB", "goedu"))
+	s := &Store{
+		client:           client,
+		db:               db,
+		users:            db.Collection("users"),
+		sessions:         db.Collection("sessions"),
+		code_submissions: db.Collection("code_submissions"),
+		tasks:            db.Collection("tasks"),
+		settings:         db.Collection("settings"),
+		courses:          db.Collection("courses"),
+		modules:          db.Collection("modules"),
+		lessons:          db.Collection("lessons"),
+		completions:      db.Collection("lesson_completions"),
+		progress:         db.Collection("task_progress"),
+		handbooks:        db.Collection("handbook_pages"),
+		tokens:           db.Collection("user_tokens"),
+		oauthStates:      db.Collection("oauth_states"),
+		securityLog:      db.Collection("security_events"),
+		goVersion:        goVersion,
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		_ = s.Close(ctx)
+		return nil, err
+	}
+	if err := s.ensureIndexes(ctx); err != nil {
+		_ = s.Close(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close disconnects from MongoDB.
+func (s *Store) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
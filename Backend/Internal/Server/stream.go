@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxStdinChunk    = 64 << 10
+	maxStdinBuffered = 256 << 10 // input sent but not yet read by the program
+)
+
+var (
+	errStdinFull   = errors.New("stdin buffer is full")
+	errStdinClosed = errors.New("stdin is closed")
+)
+
+// liveRun is a streaming run that can still be cancelled or, in
+// interactive mode, fed more input.
+type liveRun struct {
+	userID primitive.ObjectID
+	cancel context.CancelFunc
+	stdin  *stdinBuffer
+}
+
+// stdinBuffer holds input for an interactive run until the program reads
+// it. Writes never block: input beyond max is refused, so a program that
+// does not read stdin cannot hold up the request sending it.
+type stdinBuffer struct {
+	max  int
+	more chan struct{} // wakes Read after a write or close
+
+	mu     sync.Mutex
+	buf    []byte
+	eof    bool // the client has no more input
+	closed bool // the program is gone
+}
+
+func newStdinBuffer(max int) *stdinBuffer {
+	return &stdinBuffer{max: max, more: make(chan struct{}, 1)}
+}
+
+func (b *stdinBuffer) wake() {
+	select {
+	case b.more <- struct{}{}:
+	default:
+	}
+}
+
+// write queues data for the program.
+func (b *stdinBuffer) write(data string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.eof || b.closed {
+		return errStdinClosed
+	}
+	if len(b.buf)+len(data) > b.max {
+		return errStdinFull
+	}
+	b.buf = append(b.buf, data...)
+	b.wake()
+	return nil
+}
+
+// closeInput ends the program's input once it has read what is buffered.
+func (b *stdinBuffer) closeInput() {
+	b.mu.Lock()
+	b.eof = true
+	b.mu.Unlock()
+	b.wake()
+}
+
+// close is called once the run is over; later writes fail and a pending
+// Read returns.
+func (b *stdinBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.buf = nil
+	b.mu.Unlock()
+	b.wake()
+}
+
+func (b *stdinBuffer) Read(p []byte) (int, error) {
+	for {
+		b.mu.Lock()
+		if len(b.buf) > 0 {
+			n := copy(p, b.buf)
+			b.buf = b.buf[n:]
+			if len(b.buf) == 0 {
+				b.buf = nil
+			}
+			b.mu.Unlock()
+			return n, nil
+		}
+		done := b.eof || b.closed
+		b.mu.Unlock()
+		if done {
+			return 0, io.EOF
+		}
+		<-b.more
+	}
+}
+
+// handleRunStream runs code like handleRun but pushes output as
+// Server-Sent Events: start, stdout, stderr and finally exit (or error).
+// The run is killed when the client disconnects or calls
+// handleCancelRun with the id from the start event.
+func (s *Server) handleRunStream(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req runReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Stdin) > maxStdinBytes {
+		http.Error(w, "stdin too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	goVersion, err := s.runGoVersion(r.Context(), req.GoVersion, req.TaskID)
+	if err != nil {
+		writeGoVersionError(w, err)
+		return
+	}
+
+	files, err := buildWorkspace(req.Code, req.Files, goVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := s.queue.enqueue(u.ID)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+	defer ticket.release()
+
+	idb := make([]byte, 12)
+	if _, err := rand.Read(idb); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(idb)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	run := &liveRun{userID: u.ID, cancel: cancel}
+	var stdin io.Reader
+	if req.Stdin != "" {
+		stdin = strings.NewReader(req.Stdin)
+	}
+	if req.Interactive {
+		buf := newStdinBuffer(maxStdinBuffered)
+		// Refuses further input and unblocks the reader once the program
+		// is gone.
+		defer buf.close()
+		run.stdin = buf
+		if stdin != nil {
+			stdin = io.MultiReader(stdin, buf)
+		} else {
+			stdin = buf
+		}
+	}
+
+	s.liveMu.Lock()
+	s.liveRuns[id] = run
+	s.liveMu.Unlock()
+	defer func() {
+		s.liveMu.Lock()
+		delete(s.liveRuns, id)
+		s.liveMu.Unlock()
+	}()
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	_ = sse.send("start", map[string]any{
+		"id":          id,
+		"interactive": req.Interactive,
+		"goVersion":   goVersion,
+	})
+
+	err = ticket.wait(ctx, func(pos int) {
+		_ = sse.send("queued", map[string]int{"position": pos})
+	})
+	if err != nil {
+		_ = sse.send("exit", map[string]any{"exitCode": -1, "cancelled": true})
+		return
+	}
+
+	stdout, stderr := sse.stream("stdout"), sse.stream("stderr")
+	resp, err := s.runner.Run(ctx, RunJob{
+		Files:     files,
+		Cmd:       []string{"go", "run", "."},
+		GoVersion: goVersion,
+		Stdin:     stdin,
+		Stdout:    stdout,
+		Stderr:    stderr,
+	})
+	// Output that ended, or was cut off, in the middle of a character
+	// still reaches the client.
+	_ = stdout.Flush()
+	_ = stderr.Flush()
+	if err != nil {
+		_ = sse.send("error", map[string]string{"message": "run failed"})
+		return
+	}
+
+	_ = sse.send("exit", map[string]any{
+		"exitCode":  resp.ExitCode,
+		"cancelled": ctx.Err() == context.Canceled,
+	})
+}
+
+// lookupLiveRun returns the caller's live run named in the URL.
+func (s *Server) lookupLiveRun(r *http.Request) (*liveRun, bool) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	id := chi.URLParam(r, "id")
+
+	s.liveMu.Lock()
+	run, ok := s.liveRuns[id]
+	s.liveMu.Unlock()
+
+	if !ok || run.userID != u.ID {
+		return nil, false
+	}
+	return run, true
+}
+
+func (s *Server) handleCancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupLiveRun(r)
+	if !ok {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+
+	run.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRunStdin forwards input to an interactive run. Setting eof closes
+// the program's stdin after data has been read. Input is buffered up to
+// maxStdinBuffered bytes; beyond that it is refused with 429.
+func (s *Server) handleRunStdin(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupLiveRun(r)
+	if !ok {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if run.stdin == nil {
+		http.Error(w, "Run is not interactive", http.StatusConflict)
+		return
+	}
+
+	var req stdinReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Data) > maxStdinChunk {
+		http.Error(w, "stdin too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if req.Data != "" {
+		switch err := run.stdin.write(req.Data); {
+		case errors.Is(err, errStdinFull):
+			http.Error(w, "Program is not reading input fast enough, try again later", http.StatusTooManyRequests)
+			return
+		case err != nil:
+			http.Error(w, "Program is no longer reading input", http.StatusGone)
+			return
+		}
+	}
+	if req.EOF {
+		run.stdin.closeInput()
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdinBufferNeverBlocksWriters(t *testing.T) {
+	b := newStdinBuffer(8)
+
+	if err := b.write("hello"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := b.write("world"); !errors.Is(err, errStdinFull) {
+		t.Fatalf("write past the limit: %v, want errStdinFull", err)
+	}
+
+	p := make([]byte, 3)
+	if n, _ := b.Read(p); string(p[:n]) != "hel" {
+		t.Fatalf("Read = %q, want hel", p[:n])
+	}
+	// Reading frees room for more input.
+	if err := b.write("world"); err != nil {
+		t.Fatalf("write after a read: %v", err)
+	}
+
+	b.closeInput()
+	if err := b.write("late"); !errors.Is(err, errStdinClosed) {
+		t.Errorf("write after eof: %v, want errStdinClosed", err)
+	}
+	rest, err := io.ReadAll(b)
+	if err != nil || string(rest) != "loworld" {
+		t.Errorf("rest of input = %q, %v; want buffered input then EOF", rest, err)
+	}
+}
+
+func TestStdinBufferReadWaitsForInput(t *testing.T) {
+	b := newStdinBuffer(maxStdinBuffered)
+	got := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(b)
+		got <- string(data)
+	}()
+
+	for _, s := range []string{"1\n", "2\n", "3\n"} {
+		if err := b.write(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.closeInput()
+
+	select {
+	case s := <-got:
+		if s != "1\n2\n3\n" {
+			t.Errorf("program read %q", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader did not see EOF")
+	}
+}
+
+func TestStdinBufferClose(t *testing.T) {
+	b := newStdinBuffer(maxStdinBuffered)
+	done := make(chan error)
+	go func() {
+		_, err := b.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	// The run ending unblocks a reader waiting for input.
+	b.close()
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Errorf("Read after close = %v, want EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read still blocked after close")
+	}
+	if err := b.write(strings.Repeat("x", 10)); !errors.Is(err, errStdinClosed) {
+		t.Errorf("write after close: %v, want errStdinClosed", err)
+	}
+}
+
+func TestSSEStreamHoldsSplitCharacters(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, ok := newSSEWriter(rec)
+	if !ok {
+		t.Fatal("recorder cannot flush")
+	}
+	out := sse.stream("stdout")
+
+	// "é" split across writes arrives whole; the output then ends in the
+	// middle of "€", whose first two bytes only Flush sends.
+	for _, p := range []string{"caf\xc3", "\xa9 ", "\xe2\x82"} {
+		if _, err := out.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event: stdout\ndata: {\"data\":\"caf\"}\n\n" +
+		"event: stdout\ndata: {\"data\":\"é \"}\n\n" +
+		"event: stdout\ndata: {\"data\":\"\uFFFD\"}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
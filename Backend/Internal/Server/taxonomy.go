@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const tagSettingsID = "tags"
+
+// TaskTag is one entry of the tag taxonomy. Tasks refer to tags by slug.
+type TaskTag struct {
+	Slug string `bson:"slug" json:"slug"`
+	Name string `bson:"name" json:"name"`
+}
+
+// tagSettings is the admin-managed tag taxonomy, stored in the settings
+// collection.
+type tagSettings struct {
+	ID   string    `bson:"_id" json:"-"`
+	Tags []TaskTag `bson:"tags" json:"tags"`
+}
+
+func (t tagSettings) has(slug string) bool {
+	for _, tag := range t.Tags {
+		if tag.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// taskTags returns the taxonomy, which is empty until an admin saves one.
+func (s *Store) taskTags(ctx context.Context) (tagSettings, error) {
+	ts := tagSettings{ID: tagSettingsID, Tags: []TaskTag{}}
+	err := s.settings.FindOne(ctx, bson.M{"_id": tagSettingsID}).Decode(&ts)
+	if err == mongo.ErrNoDocuments {
+		return ts, nil
+	}
+	return ts, err
+}
+
+func (s *Server) handleListTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	ts, err := s.taskTags(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ts)
+}
+
+// handleAdminUpdateTags replaces the taxonomy. A tag still used by a task
+// cannot be removed.
+func (s *Server) handleAdminUpdateTags(w http.ResponseWriter, r *http.Request) {
+	var req tagSettings
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	errs := map[string]string{}
+	seen := map[string]bool{}
+	for i, t := range req.Tags {
+		t.Slug = strings.TrimSpace(t.Slug)
+		t.Name = strings.TrimSpace(t.Name)
+		if t.Name == "" {
+			t.Name = t.Slug
+		}
+		switch {
+		case !slugRegex.MatchString(t.Slug):
+			errs[fmt.Sprintf("tags[%d].slug", i)] = "must be lowercase letters and digits joined by dashes"
+		case seen[t.Slug]:
+			errs[fmt.Sprintf("tags[%d].slug", i)] = "duplicate"
+		}
+		if len(t.Name) > 50 {
+			errs[fmt.Sprintf("tags[%d].name", i)] = "must be at most 50 characters"
+		}
+		seen[t.Slug] = true
+		req.Tags[i] = t
+	}
+	if req.Tags == nil {
+		req.Tags = []TaskTag{}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	old, err := s.taskTags(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	for _, t := range old.Tags {
+		if seen[t.Slug] {
+			continue
+		}
+		n, err := s.tasks.CountDocuments(ctx, bson.M{"tags": t.Slug})
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n > 0 {
+			errs["tags"] = fmt.Sprintf("%s is still used by %d tasks", t.Slug, n)
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	req.ID = tagSettingsID
+	_, err = s.settings.ReplaceOne(ctx, bson.M{"_id": tagSettingsID}, req, options.Replace().SetUpsert(true))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
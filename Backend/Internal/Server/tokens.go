@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	tokenPasswordReset = "password_reset"
+	tokenEmailVerify   = "email_verify"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// newToken returns a random token for the client and the hash stored in
+// its place, as for sessions.
+func newToken() (raw string, hash []byte, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	th := sha256.Sum256([]byte(raw))
+	return raw, th[:], nil
+}
+
+// issueToken stores a single-use token for purpose, replacing any earlier
+// one the user had for the same purpose, and returns it for the email.
+// email records the address the token vouches for, if any.
+func (s *Server) issueToken(ctx context.Context, userID primitive.ObjectID, purpose, email string, ttl time.Duration) (string, error) {
+	raw, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.tokens.DeleteMany(ctx, bson.M{"userId": userID, "purpose": purpose}); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = s.tokens.InsertOne(ctx, userTokenDoc{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Email:     email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeToken redeems a token, deleting it so it cannot be used again.
+func (s *Server) consumeToken(ctx context.Context, raw, purpose string) (userTokenDoc, error) {
+	th := sha256.Sum256([]byte(raw))
+
+	var t userTokenDoc
+	err := s.tokens.FindOneAndDelete(ctx, bson.M{
+		"tokenHash": th[:],
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return userTokenDoc{}, errInvalidToken
+	}
+	return t, err
+}
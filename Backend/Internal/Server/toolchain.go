@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const toolchainSettingsID = "toolchains"
+
+var (
+	goVersionRegex = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+
+	errToolchainNotAllowed = errors.New("go version is not allowed")
+	errTaskNotFound        = errors.New("task not found")
+)
+
+// toolchainSettings is the admin-managed allowlist of Go versions, stored
+// in the settings collection.
+type toolchainSettings struct {
+	ID       string   `bson:"_id" json:"-"`
+	Versions []string `bson:"versions" json:"versions"`
+	Default  string   `bson:"default" json:"default"`
+}
+
+func (t toolchainSettings) allows(v string) bool {
+	for _, a := range t.Versions {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// toolchains returns the allowlist, falling back to just the GO_VERSION
+// default until an admin has saved one.
+func (s *Store) toolchains(ctx context.Context) (toolchainSettings, error) {
+	var ts toolchainSettings
+	err := s.settings.FindOne(ctx, bson.M{"_id": toolchainSettingsID}).Decode(&ts)
+	if err == mongo.ErrNoDocuments {
+		return toolchainSettings{Versions: []string{s.goVersion}, Default: s.goVersion}, nil
+	}
+	return ts, err
+}
+
+// resolveGoVersion picks the toolchain for a run: the one requested if it
+// is allowed, else the task's pinned version, else the global default.
+func (s *Server) resolveGoVersion(ctx context.Context, requested string, task *Task) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	ts, err := s.toolchains(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case requested != "":
+		if !ts.allows(requested) {
+			return "", errToolchainNotAllowed
+		}
+		return requested, nil
+	case task != nil && task.GoVersion != "":
+		return task.GoVersion, nil
+	default:
+		return ts.Default, nil
+	}
+}
+
+// runGoVersion resolves the toolchain for a run, format or similar
+// request, honouring the version pinned by the task the code belongs to.
+func (s *Server) runGoVersion(ctx context.Context, requested, taskID string) (string, error) {
+	if taskID == "" || requested != "" {
+		return s.resolveGoVersion(ctx, requested, nil)
+	}
+
+	id, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		return "", errTaskNotFound
+	}
+
+	fctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var task Task
+	opts := options.FindOne().SetProjection(bson.M{"goVersion": 1})
+	if err := s.tasks.FindOne(fctx, bson.M{"_id": id}, opts).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", errTaskNotFound
+		}
+		return "", err
+	}
+	return s.resolveGoVersion(ctx, "", &task)
+}
+
+// writeGoVersionError reports a resolveGoVersion failure.
+func writeGoVersionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errToolchainNotAllowed):
+		http.Error(w, "Go version is not allowed", http.StatusBadRequest)
+	case errors.Is(err, errTaskNotFound):
+		http.Error(w, "Task not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Server error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleListToolchains(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	ts, err := s.toolchains(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ts)
+}
+
+func (s *Server) handleAdminUpdateToolchains(w http.ResponseWriter, r *http.Request) {
+	var req toolchainSettings
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Versions) == 0 {
+		http.Error(w, "At least one version is required", http.StatusBadRequest)
+		return
+	}
+	for _, v := range req.Versions {
+		if !goVersionRegex.MatchString(v) {
+			http.Error(w, "Invalid Go version: "+v, http.StatusBadRequest)
+			return
+		}
+	}
+	if !req.allows(req.Default) {
+		http.Error(w, "Default must be one of the versions", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req.ID = toolchainSettingsID
+	_, err := s.settings.ReplaceOne(ctx, bson.M{"_id": toolchainSettingsID}, req, options.Replace().SetUpsert(true))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, req)
+}
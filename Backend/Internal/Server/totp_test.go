@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	key := []byte("12345678901234567890")
+	for _, tt := range tests {
+		if got := hotp(key, uint64(tt.unix/totpPeriod)); got != tt.code {
+			t.Errorf("hotp at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestTOTPStep(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	now := at.Unix() / totpPeriod
+
+	if step, ok := totpStep(rfcSecret, "081804", at); !ok || step != now {
+		t.Errorf("current code: step %d, %v; want %d, true", step, ok, now)
+	}
+	if _, ok := totpStep(strings.ToLower(rfcSecret), "081 804", at); !ok {
+		t.Error("lower-case secret or spaced code rejected")
+	}
+
+	// Codes one period either side are accepted for clock drift, and
+	// report the step they belong to.
+	for _, d := range []int64{-1, 1} {
+		code := hotp([]byte("12345678901234567890"), uint64(now+d))
+		if step, ok := totpStep(rfcSecret, code, at); !ok || step != now+d {
+			t.Errorf("code for step %+d: step %d, %v; want %d, true", d, step, ok, now+d)
+		}
+	}
+
+	rejected := map[string]string{
+		"outside the skew": hotp([]byte("12345678901234567890"), uint64(now+2)),
+		"wrong code":       "123456",
+		"too short":        "08180",
+		"too long":         "0818040",
+	}
+	for name, code := range rejected {
+		if _, ok := totpStep(rfcSecret, code, at); ok {
+			t.Errorf("%s: %q accepted", name, code)
+		}
+	}
+	if _, ok := totpStep("not base32!", "081804", at); ok {
+		t.Error("malformed secret accepted")
+	}
+}
+
+func TestTOTPFreshStepRefusesReplay(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	key := []byte("12345678901234567890")
+	now := at.Unix() / totpPeriod
+
+	step, ok := totpFreshStep(rfcSecret, "081804", 0, at)
+	if !ok || step != now {
+		t.Fatalf("first use: step %d, %v; want %d, true", step, ok, now)
+	}
+	if _, ok := totpFreshStep(rfcSecret, "081804", step, at); ok {
+		t.Error("the same code was accepted twice")
+	}
+	if _, ok := totpFreshStep(rfcSecret, hotp(key, uint64(now-1)), step, at); ok {
+		t.Error("an older code inside the skew window was accepted after a newer one")
+	}
+	if next, ok := totpFreshStep(rfcSecret, hotp(key, uint64(now+1)), step, at); !ok || next != now+1 {
+		t.Errorf("next period's code: step %d, %v; want %d, true", next, ok, now+1)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(totpURI("SECRET", "ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/GoEdu:ada@example.com" {
+		t.Errorf("uri = %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "SECRET" || q.Get("issuer") != totpIssuer || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes(numRecoveryCodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != numRecoveryCodes || len(hashes) != numRecoveryCodes {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), numRecoveryCodes)
+	}
+	seen := map[string]bool{}
+	for i, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Errorf("code %q is not xxxxx-xxxxx", c)
+		}
+		if seen[c] {
+			t.Errorf("duplicate code %q", c)
+		}
+		seen[c] = true
+
+		// Codes are matched however the user types them.
+		typed := " " + strings.ToUpper(strings.Replace(c, "-", "", 1)) + " "
+		if string(recoveryCodeHash(typed)) != string(hashes[i]) {
+			t.Errorf("hash of %q does not match %q", typed, c)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const securitySettingsID = "security"
+
+// securitySettings holds admin-managed account policy, stored in the
+// settings collection.
+type securitySettings struct {
+	ID                    string `bson:"_id" json:"-"`
+	RequireAdminTwoFactor bool   `bson:"requireAdminTwoFactor" json:"requireAdminTwoFactor"`
+}
+
+func (s *Server) securitySettings(ctx context.Context) (securitySettings, error) {
+	ss := securitySettings{ID: securitySettingsID}
+	err := s.settings.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&ss)
+	if err == mongo.ErrNoDocuments {
+		return ss, nil
+	}
+	return ss, err
+}
+
+// checkSecondFactor accepts either a current TOTP code or one of the
+// user's unused recovery codes, and uses it up either way: a TOTP step is
+// never accepted twice and a recovery code is removed.
+func (s *Server) checkSecondFactor(ctx context.Context, u userDoc, req twoFactorCodeReq) (bool, error) {
+	if req.RecoveryCode != "" {
+		h := recoveryCodeHash(req.RecoveryCode)
+		res, err := s.users.UpdateOne(ctx,
+			bson.M{"_id": u.ID, "recoveryCodes": h},
+			bson.M{"$pull": bson.M{"recoveryCodes": h}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return res.ModifiedCount == 1, nil
+	}
+
+	step, ok := totpFreshStep(u.TOTPSecret, req.Code, u.TOTPLastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// The filter repeats the check atomically for concurrent logins.
+	res, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": u.ID, "totpLastStep": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"totpLastStep": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// handleTwoFactorSetup starts enrollment with a fresh secret. It is not in
+// force until handleTwoFactorEnable sees a code generated from it.
+func (s *Server) handleTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+	if u.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	uri := totpURI(secret, u.Email)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err = s.users.UpdateByID(ctx, u.ID, bson.M{"$set": bson.M{"totpSecret": secret}})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TwoFactorSetupResp{
+		Secret: secret,
+		URI:    uri,
+		QR:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// handleTwoFactorEnable turns on 2FA once the user proves their app works,
+// and returns the recovery codes, which are never shown again.
+func (s *Server) handleTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req twoFactorCodeReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if u.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if u.TOTPSecret == "" {
+		http.Error(w, "Start two-factor setup first", http.StatusConflict)
+		return
+	}
+	step, ok := totpStep(u.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes(numRecoveryCodes)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": u.ID, "totpSecret": u.TOTPSecret, "totpEnabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{
+			"totpEnabled":   true,
+			"totpLastStep":  step,
+			"recoveryCodes": hashes,
+		}},
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if res.MatchedCount == 0 {
+		http.Error(w, "Two-factor setup changed, start again", http.StatusConflict)
+		return
+	}
+	s.logSecurityEvent(ctx, r, u.ID, eventTwoFactorEnabled)
+
+	writeJSON(w, http.StatusOK, RecoveryCodesResp{RecoveryCodes: codes})
+}
+
+// handleTwoFactorDisable turns 2FA off. It takes both the password and a
+// second factor, so neither a stolen session nor a stolen phone is enough.
+func (s *Server) handleTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req disableTwoFactorReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !u.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+	if bcrypt.CompareHashAndPassword(u.PassHash, []byte(req.Password)) != nil {
+		http.Error(w, "Wrong password", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if u.Role == "admin" {
+		sec, err := s.securitySettings(ctx)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if sec.RequireAdminTwoFactor {
+			http.Error(w, "Admins must keep two-factor authentication enabled", http.StatusForbidden)
+			return
+		}
+	}
+
+	ok, err := s.checkSecondFactor(ctx, u, req.twoFactorCodeReq)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusForbidden)
+		return
+	}
+
+	_, err = s.users.UpdateByID(ctx, u.ID, bson.M{"$unset": bson.M{
+		"totpSecret":    "",
+		"totpEnabled":   "",
+		"totpLastStep":  "",
+		"recoveryCodes": "",
+	}})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	s.logSecurityEvent(ctx, r, u.ID, eventTwoFactorDisabled)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Two-factor authentication disabled"})
+}
+
+// handleRegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req twoFactorCodeReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !u.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	ok, err := s.checkSecondFactor(ctx, u, twoFactorCodeReq{Code: req.Code})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusForbidden)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes(numRecoveryCodes)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.users.UpdateByID(ctx, u.ID, bson.M{"$set": bson.M{"recoveryCodes": hashes}}); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RecoveryCodesResp{RecoveryCodes: codes})
+}
+
+// handleLoginTwoFactor is the second step of logging in with 2FA. It
+// trades the pending session handleLogin issued for a full one.
+func (s *Server) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req twoFactorCodeReq
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	raw, err := readSessionCookie(r)
+	if err != nil {
+		http.Error(w, "Login expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+	th := sha256.Sum256([]byte(raw))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var sess sessionDoc
+	err = s.sessions.FindOne(ctx, bson.M{
+		"tokenHash": th[:],
+		"pending":   true,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(&sess)
+	if err != nil {
+		http.Error(w, "Login expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+
+	var u userDoc
+	if err := s.users.FindOne(ctx, bson.M{"_id": sess.UserID}).Decode(&u); err != nil {
+		http.Error(w, "Login expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := s.checkSecondFactor(ctx, u, req)
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		// A pending session gets a few tries; after that the password has
+		// to be entered again.
+		var after sessionDoc
+		err := s.sessions.FindOneAndUpdate(ctx,
+			bson.M{"_id": sess.ID},
+			bson.M{"$inc": bson.M{"attempts": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&after)
+		if err != nil || after.Attempts >= max2FATries {
+			_, _ = s.sessions.DeleteOne(ctx, bson.M{"_id": sess.ID})
+			clearCookie(w, s.devMode)
+			http.Error(w, "Too many attempts, please log in again", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := s.sessions.DeleteOne(ctx, bson.M{"_id": sess.ID}); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.createSession(w, r, u.ID); err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("Logged in"))
+}
+
+func (s *Server) handleAdminGetSecurity(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	sec, err := s.securitySettings(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sec)
+}
+
+func (s *Server) handleAdminUpdateSecurity(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	var req securitySettings
+	if err := decodeJSON(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	// Requiring 2FA without having it would lock the admin out at once.
+	if req.RequireAdminTwoFactor && !u.TOTPEnabled {
+		writeValidationErrors(w, map[string]string{
+			"requireAdminTwoFactor": "enable two-factor authentication on your own account first",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	req.ID = securitySettingsID
+	_, err := s.settings.ReplaceOne(ctx, bson.M{"_id": securitySettingsID}, req, options.Replace().SetUpsert(true))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
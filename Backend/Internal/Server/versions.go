@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errVersionConflict = errors.New("could not allocate a version number")
+
+func (d codeSubmissionDoc) summary() submissionVersionResp {
+	return submissionVersionResp{
+		Version:      d.Version,
+		RestoredFrom: d.RestoredFrom,
+		CreatedAt:    d.CreatedAt,
+	}
+}
+
+// tree returns the version's files, treating single-file saves as main.go.
+func (d codeSubmissionDoc) tree() []SourceFile {
+	if len(d.Files) > 0 {
+		return d.Files
+	}
+	return []SourceFile{{Path: "main.go", Content: d.Code}}
+}
+
+func (s *Server) latestVersion(ctx context.Context, userID primitive.ObjectID, lessonID string) (codeSubmissionDoc, error) {
+	var doc codeSubmissionDoc
+	err := s.code_submissions.FindOne(ctx,
+		bson.M{"userId": userID, "lessonId": lessonID},
+		options.FindOne().SetSort(bson.M{"version": -1}),
+	).Decode(&doc)
+	return doc, err
+}
+
+// saveVersion stores doc as the next version of its lesson. Saving
+// content identical to the latest version returns that version instead
+// of adding a duplicate.
+func (s *Server) saveVersion(ctx context.Context, doc codeSubmissionDoc) (codeSubmissionDoc, error) {
+	// Two concurrent saves can pick the same number; the unique index
+	// rejects the loser, which then tries the next one.
+	for attempt := 0; attempt < 3; attempt++ {
+		latest, err := s.latestVersion(ctx, doc.UserID, doc.LessonID)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			doc.Version = 1
+		case err != nil:
+			return codeSubmissionDoc{}, err
+		case latest.Code == doc.Code && reflect.DeepEqual(latest.tree(), doc.tree()):
+			return latest, nil
+		default:
+			doc.Version = latest.Version + 1
+		}
+
+		doc.ID = primitive.NewObjectID()
+		doc.CreatedAt = time.Now().UTC()
+		_, err = s.code_submissions.InsertOne(ctx, doc)
+		if err == nil {
+			return doc, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return codeSubmissionDoc{}, err
+		}
+	}
+	return codeSubmissionDoc{}, errVersionConflict
+}
+
+// findVersion loads the version named by the {version} URL parameter and
+// writes an error response when it cannot.
+func (s *Server) findVersion(ctx context.Context, w http.ResponseWriter, r *http.Request, param string) (codeSubmissionDoc, bool) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	v, err := strconv.Atoi(param)
+	if err != nil || v < 1 {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return codeSubmissionDoc{}, false
+	}
+
+	var doc codeSubmissionDoc
+	err = s.code_submissions.FindOne(ctx, bson.M{
+		"userId":   u.ID,
+		"lessonId": chi.URLParam(r, "lessonId"),
+		"version":  v,
+	}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return codeSubmissionDoc{}, false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return codeSubmissionDoc{}, false
+	}
+	return doc, true
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxUserKey{}).(userDoc)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"version": -1}).
+		SetProjection(bson.M{"code": 0, "files": 0})
+	cur, err := s.code_submissions.Find(ctx, bson.M{
+		"userId":   u.ID,
+		"lessonId": chi.URLParam(r, "lessonId"),
+	}, opts)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var docs []codeSubmissionDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	versions := make([]submissionVersionResp, 0, len(docs))
+	for _, d := range docs {
+		versions = append(versions, d.summary())
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	doc, ok := s.findVersion(ctx, w, r, chi.URLParam(r, "version"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// handleDiffVersions compares ?from=N with ?to=M for a lesson.
+func (s *Server) handleDiffVersions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	from, ok := s.findVersion(ctx, w, r, r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := s.findVersion(ctx, w, r, r.URL.Query().Get("to"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":  from.Version,
+		"to":    to.Version,
+		"files": diffFiles(from.tree(), to.tree()),
+	})
+}
+
+// handleRestoreVersion saves an old version's content as a new latest
+// version, so restoring never loses history.
+func (s *Server) handleRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	old, ok := s.findVersion(ctx, w, r, chi.URLParam(r, "version"))
+	if !ok {
+		return
+	}
+
+	doc, err := s.saveVersion(ctx, codeSubmissionDoc{
+		UserID:       old.UserID,
+		LessonID:     old.LessonID,
+		Code:         old.Code,
+		Files:        old.Files,
+		RestoredFrom: old.Version,
+	})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	maxWorkspaceFiles = 50
+	maxWorkspaceBytes = 1 << 20
+)
+
+// workspaceFileExts lists what may be submitted besides go.mod and go.sum.
+var workspaceFileExts = map[string]bool{
+	".go":   true,
+	".txt":  true,
+	".md":   true,
+	".json": true,
+}
+
+// buildWorkspace turns a submission into runner files. Older clients send
+// a single Code string, which becomes main.go; newer ones send a file tree.
+// A go.mod for goVersion is added when the submission does not bring its
+// own, so main can import its subpackages as play/<dir>.
+func buildWorkspace(code string, files []SourceFile, goVersion string) (map[string]string, error) {
+	files = submittedFiles(code, files)
+	if err := validateFiles(files); err != nil {
+		return nil, err
+	}
+
+	ws := make(map[string]string, len(files)+1)
+	for _, f := range files {
+		ws[path.Clean(f.Path)] = f.Content
+	}
+	if _, ok := ws["go.mod"]; !ok {
+		ws["go.mod"] = "module play\n\ngo " + goVersion + "\n"
+	}
+	return ws, nil
+}
+
+// submittedFiles is the file tree a submission was made with, before any
+// go.mod is added.
+func submittedFiles(code string, files []SourceFile) []SourceFile {
+	if len(files) == 0 {
+		return []SourceFile{{Path: "main.go", Content: code}}
+	}
+	return files
+}
+
+// validateFiles checks a file tree submitted by a user or an admin.
+func validateFiles(files []SourceFile) error {
+	if len(files) > maxWorkspaceFiles {
+		return fmt.Errorf("at most %d files are allowed", maxWorkspaceFiles)
+	}
+
+	total := 0
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		p := path.Clean(f.Path)
+		if f.Path == "" || path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") || strings.Contains(f.Path, `\`) {
+			return fmt.Errorf("invalid path %q", f.Path)
+		}
+		for _, part := range strings.Split(p, "/") {
+			if strings.HasPrefix(part, ".") {
+				return fmt.Errorf("invalid path %q", f.Path)
+			}
+		}
+		base := path.Base(p)
+		if base != "go.mod" && base != "go.sum" && !workspaceFileExts[path.Ext(base)] {
+			return fmt.Errorf("file type not allowed: %q", f.Path)
+		}
+		if seen[p] {
+			return fmt.Errorf("duplicate file %q", f.Path)
+		}
+		seen[p] = true
+
+		total += len(f.Content)
+		if total > maxWorkspaceBytes {
+			return errors.New("submission is too large")
+		}
+	}
+	return nil
+}
+
+// withoutTests drops the user's own _test.go files so they cannot shadow
+// or sabotage the hidden tests.
+func withoutTests(ws map[string]string) map[string]string {
+	for p := range ws {
+		if strings.HasSuffix(p, "_test.go") {
+			delete(ws, p)
+		}
+	}
+	return ws
+}
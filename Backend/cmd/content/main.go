@@ -0,0 +1,66 @@
+// Command content imports and exports task bundles, so the curriculum can
+// live in git:
+//
+//	content import <dir>   upsert every bundle under dir, matched by slug
+//	content export <dir>   write every task to dir/<slug>
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	server "goedu/Internal/Server"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: content import|export <dir>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		usage()
+	}
+	cmd, dir := os.Args[1], os.Args[2]
+	if cmd != "import" && cmd != "export" {
+		usage()
+	}
+
+	if err := run(cmd, dir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run does the work of main, returning errors so the database connection
+// is always closed. It needs only the database, not the HTTP server's
+// runner, mailer or login providers.
+func run(cmd, dir string) (err error) {
+	ctx := context.Background()
+	s, err := server.NewStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := s.Close(ctx); err == nil {
+			err = cerr
+		}
+	}()
+
+	switch cmd {
+	case "import":
+		res, err := s.ImportTasks(ctx, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %d, updated %d, unchanged %d\n", res.Created, res.Updated, res.Unchanged)
+	case "export":
+		n, err := s.ExportTasks(ctx, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("exported %d tasks\n", n)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Command mockoidc is a minimal OpenID Connect provider for trying out and
+// testing "Sign in with ..." locally. Every authorization request is
+// approved at once as the user given by the flags; PKCE (S256) is required.
+//
+//	mockoidc -addr :9999 -email ada@example.com
+//
+// and run the server with
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9999
+//	OIDC_MOCK_CLIENT_ID=goedu
+//	OIDC_MOCK_CLIENT_SECRET=secret
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"goedu/Internal/mockoidc"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "listen address")
+	issuer := flag.String("issuer", "", "issuer URL (default http://<addr>)")
+	clientID := flag.String("client-id", "goedu", "accepted client ID")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	email := flag.String("email", "student@example.com", "email of the signed-in user")
+	name := flag.String("name", "Mock Student", "name of the signed-in user")
+	verified := flag.Bool("verified", true, "report the email as verified")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	p, err := mockoidc.New(mockoidc.Config{
+		Issuer:       *issuer,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		Email:        *email,
+		Name:         *name,
+		Verified:     *verified,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("mock OIDC provider %s signing in as %s", p.Issuer(), *email)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
@@ -16,6 +16,12 @@ async function loadProfile() {
     if (nameInput) nameInput.value = user.name;
     if (emailInput) emailInput.value = user.email;
 
+    showEmailNotice(user);
+    showTwoFactor(user.twoFactorEnabled);
+
+    const passwordCard = document.getElementById("passwordCard");
+    if (passwordCard) passwordCard.style.display = "block";
+
     const photoSrc = user.photo ? "/Profile-Images/" + user.photo : "/Profile-Images/default.jpg";
     updateProfileImages(photoSrc);
 
@@ -39,6 +45,127 @@ async function loadProfile() {
   }
 }
 
+function showEmailNotice(user) {
+  const notice = document.getElementById("emailNotice");
+  const text = document.getElementById("emailNoticeText");
+  if (!notice || !text) return;
+
+  if (user.pendingEmail) {
+    text.textContent = `Check ${user.pendingEmail} to confirm your new address.`;
+  } else if (!user.emailVerified) {
+    text.textContent = "Your email is not confirmed yet.";
+  } else {
+    notice.style.display = "none";
+    return;
+  }
+  notice.style.display = "flex";
+}
+
+function setupResendVerification() {
+  const resendBtn = document.getElementById("resendBtn");
+  if (!resendBtn) return;
+
+  resendBtn.addEventListener("click", async () => {
+    try {
+      const res = await fetch("/api/email/resend", {
+        method: "POST",
+        credentials: "same-origin",
+      });
+      const text = await res.text();
+      if (!res.ok) throw new Error(text || "Failed to resend confirmation");
+
+      alert(JSON.parse(text).status);
+    } catch (e) {
+      alert(e.message);
+      console.error(e);
+    }
+  });
+}
+
+function showTwoFactor(enabled) {
+  const card = document.getElementById("twoFactorCard");
+  if (!card) return;
+
+  card.style.display = "block";
+  document.getElementById("twoFactorStatus").textContent = enabled ? "On" : "Off";
+  document.getElementById("twoFactorEnableBtn").style.display = enabled ? "none" : "inline-block";
+  document.getElementById("recoveryCodesBtn").style.display = enabled ? "inline-block" : "none";
+  document.getElementById("twoFactorDisableBtn").style.display = enabled ? "inline-block" : "none";
+}
+
+function showRecoveryCodes(codes) {
+  const el = document.getElementById("recoveryCodes");
+  el.textContent = "Save these recovery codes somewhere safe. Each works once.\n\n" + codes.join("\n");
+  el.style.display = "block";
+}
+
+async function postJSON(url, payload) {
+  const res = await fetch(url, {
+    method: "POST",
+    credentials: "same-origin",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(payload || {})
+  });
+  const text = await res.text();
+  if (!res.ok) throw new Error(text || "Request failed");
+  return JSON.parse(text);
+}
+
+function setupTwoFactor() {
+  const enableBtn = document.getElementById("twoFactorEnableBtn");
+  if (!enableBtn) return;
+
+  const setupEl = document.getElementById("twoFactorSetup");
+
+  enableBtn.addEventListener("click", async () => {
+    try {
+      const data = await postJSON("/api/2fa/setup");
+      document.getElementById("twoFactorQR").src = data.qr;
+      document.getElementById("twoFactorSecret").textContent = data.secret;
+      setupEl.style.display = "block";
+    } catch (e) {
+      alert(e.message);
+    }
+  });
+
+  document.getElementById("twoFactorConfirmBtn").addEventListener("click", async () => {
+    try {
+      const code = document.getElementById("twoFactorCode").value.trim();
+      const data = await postJSON("/api/2fa/enable", { code });
+      setupEl.style.display = "none";
+      showRecoveryCodes(data.recoveryCodes);
+      showTwoFactor(true);
+    } catch (e) {
+      alert(e.message);
+    }
+  });
+
+  document.getElementById("recoveryCodesBtn").addEventListener("click", async () => {
+    const code = prompt("Enter a code from your authenticator app");
+    if (!code) return;
+    try {
+      const data = await postJSON("/api/2fa/recovery-codes", { code: code.trim() });
+      showRecoveryCodes(data.recoveryCodes);
+    } catch (e) {
+      alert(e.message);
+    }
+  });
+
+  document.getElementById("twoFactorDisableBtn").addEventListener("click", async () => {
+    const password = prompt("Enter your password");
+    if (!password) return;
+    const code = prompt("Enter a code from your authenticator app");
+    if (!code) return;
+    try {
+      await postJSON("/api/2fa/disable", { password, code: code.trim() });
+      document.getElementById("recoveryCodes").style.display = "none";
+      showTwoFactor(false);
+    } catch (e) {
+      alert(e.message);
+    }
+  });
+}
+
 function updateProfileImages(photoUrl) {
   const profileImgs = document.querySelectorAll(".profile-img");
   profileImgs.forEach(img => {
@@ -98,12 +225,16 @@ function setupProfileEdit() {
         });
 
         if (!res.ok) {
-          const data = await res.json();
-          throw new Error(data.error || "Update failed");
+          const text = await res.text();
+          let message = text;
+          try { message = JSON.parse(text).error; } catch {}
+          throw new Error(message || "Update failed");
         }
 
+        const data = await res.json();
         profileName.textContent = updatedName;
-        profileEmail.textContent = updatedEmail;
+        if (!data.pendingEmail) profileEmail.textContent = updatedEmail;
+        loadProfile();
 
         profileName.style.display = "inline";
         profileEmail.style.display = "inline";
@@ -113,7 +244,9 @@ function setupProfileEdit() {
         saveBtn.style.display = "none";
         cancelBtn.style.display = "none";
 
-        alert("Profile updated successfully!");
+        alert(data.pendingEmail
+          ? `Profile updated. Confirm ${data.pendingEmail} to finish changing your email.`
+          : "Profile updated successfully!");
       } catch (e) {
         alert(e.message);
         console.error(e);
@@ -206,6 +339,159 @@ function setupDeleteAccount() {
   }
 }
 
+async function loadSessions() {
+  const card = document.getElementById("sessionsCard");
+  const list = document.getElementById("sessionsList");
+  if (!card || !list) return;
+
+  const res = await fetch("/api/sessions", { credentials: "same-origin" });
+  if (!res.ok) return;
+
+  const sessions = await res.json();
+  list.innerHTML = "";
+  sessions.forEach(sess => {
+    const row = document.createElement("div");
+    row.className = "profile-field";
+
+    const info = document.createElement("span");
+    const seen = new Date(sess.lastSeenAt).toLocaleString();
+    info.textContent = `${sess.userAgent || "Unknown device"} — ${sess.ip} — last active ${seen}`;
+    row.appendChild(info);
+
+    if (sess.current) {
+      const tag = document.createElement("strong");
+      tag.textContent = "This device";
+      row.appendChild(tag);
+    } else {
+      const btn = document.createElement("button");
+      btn.className = "secondary-btn";
+      btn.textContent = "Log out";
+      btn.addEventListener("click", async () => {
+        const res = await fetch(`/api/sessions/${sess.id}`, {
+          method: "DELETE",
+          credentials: "same-origin",
+        });
+        if (!res.ok) {
+          alert("Failed to log out that session");
+          return;
+        }
+        loadSessions();
+      });
+      row.appendChild(btn);
+    }
+    list.appendChild(row);
+  });
+  card.style.display = "block";
+}
+
+function setupRevokeOthers() {
+  const btn = document.getElementById("revokeOthersBtn");
+  if (!btn) return;
+
+  btn.addEventListener("click", async () => {
+    if (!confirm("Log out all other devices?")) return;
+    try {
+      const data = await postJSON("/api/sessions/revoke-others");
+      alert(`Logged out ${data.revoked} other session(s)`);
+      loadSessions();
+    } catch (e) {
+      alert(e.message);
+    }
+  });
+}
+
+const securityEventLabels = {
+  password_changed: "Password changed",
+  password_reset: "Password reset by email",
+  email_changed: "Email address changed",
+  two_factor_enabled: "Two-factor authentication enabled",
+  two_factor_disabled: "Two-factor authentication disabled",
+};
+
+async function loadSecurityLog() {
+  const card = document.getElementById("securityLogCard");
+  const list = document.getElementById("securityLog");
+  if (!card || !list) return;
+
+  const res = await fetch("/api/security-log", { credentials: "same-origin" });
+  if (!res.ok) return;
+
+  const events = await res.json();
+  list.innerHTML = "";
+  if (events.length === 0) {
+    list.textContent = "No changes yet.";
+  }
+  events.forEach(ev => {
+    const row = document.createElement("div");
+    row.className = "profile-field";
+    const when = new Date(ev.createdAt).toLocaleString();
+    row.textContent = `${securityEventLabels[ev.type] || ev.type} — ${when} from ${ev.ip}`;
+    list.appendChild(row);
+  });
+  card.style.display = "block";
+}
+
+function setupChangePassword() {
+  const form = document.getElementById("passwordForm");
+  if (!form) return;
+
+  form.addEventListener("submit", async (e) => {
+    e.preventDefault();
+
+    const newPassword = form.elements.newPassword.value;
+    if (newPassword !== form.elements.confirm.value) {
+      alert("Passwords do not match");
+      return;
+    }
+
+    try {
+      const data = await postJSON("/api/password/change", {
+        currentPassword: form.elements.currentPassword.value,
+        newPassword
+      });
+      form.reset();
+      alert(data.status + ". Other devices have been logged out.");
+      loadSecurityLog();
+      loadSessions();
+    } catch (err) {
+      alert(err.message);
+    }
+  });
+}
+
+async function loadProgress() {
+  const card = document.getElementById("progressCard");
+  const solvedEl = document.getElementById("progressSolved");
+  const coursesEl = document.getElementById("progressCourses");
+  if (!card || !solvedEl || !coursesEl) return;
+
+  try {
+    const res = await fetch("/api/progress", { credentials: "same-origin" });
+    if (!res.ok) return;
+
+    const progress = await res.json();
+    solvedEl.textContent = progress.solved;
+
+    coursesEl.innerHTML = "";
+    progress.courses.forEach(c => {
+      const row = document.createElement("div");
+      row.className = "profile-field";
+
+      const title = document.createElement("span");
+      title.textContent = c.title + ":";
+      const value = document.createElement("span");
+      value.textContent = `${c.completedLessons}/${c.totalLessons} lessons (${c.percent}%)`;
+
+      row.append(title, value);
+      coursesEl.appendChild(row);
+    });
+
+    card.style.display = "";
+  } catch (e) {
+    console.error("Failed to load progress:", e);
+  }
+}
+
 async function checkAdmin() {
     try {
         const res = await fetch("/api/me", { credentials: "same-origin" });
@@ -232,8 +518,15 @@ async function checkAdmin() {
 document.addEventListener("DOMContentLoaded", () => {
   loadProfile();
   setupProfileEdit();
+  setupResendVerification();
+  setupTwoFactor();
   setupPhotoUpload();
   setupLogout();
   setupDeleteAccount();
+  loadProgress();
+  loadSessions();
+  setupRevokeOthers();
+  setupChangePassword();
+  loadSecurityLog();
   checkAdmin();
 });